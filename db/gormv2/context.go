@@ -0,0 +1,42 @@
+package gormv2
+
+import (
+	"context"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+type key int
+
+const gormKey key = iota
+
+func WithGorm(db *gorm.DB) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r.WithContext(GormContext(r.Context(), db)))
+		})
+	}
+}
+
+// GormContext installs a new session of db in the returned
+// context. The session carries the context, so that a `Logger` will
+// log via the context's logger, if any.
+func GormContext(c context.Context, db *gorm.DB) context.Context {
+	return context.WithValue(c, gormKey, db.WithContext(c))
+}
+
+func Gorm(c context.Context) (*gorm.DB, bool) {
+	db, ok := c.Value(gormKey).(*gorm.DB)
+	return db, ok
+}
+
+func MustGetGorm(c context.Context) *gorm.DB {
+	db, ok := Gorm(c)
+
+	if !ok {
+		panic("can not find gorm in context")
+	}
+
+	return db
+}
@@ -0,0 +1,35 @@
+// Package gormv2 provides a common way to setup a `gorm.io/gorm` (v2)
+// `gorm.DB` object, alongside the `jinzhu/gorm` support in
+// `appkit/db`, so that apps can migrate incrementally.
+package gormv2
+
+import (
+	"fmt"
+
+	"github.com/theplant/appkit/log"
+	"gorm.io/gorm"
+)
+
+// New creates a DB object using the given dialector (eg.
+// `postgres.Open(params)` from `gorm.io/driver/postgres`), logging
+// via the given logger.
+func New(l log.Logger, dialector gorm.Dialector, opts ...gorm.Option) (*gorm.DB, error) {
+	l = l.With("context", "appkit/db/gormv2.New")
+	l.Debug().Log("msg", "opening database connection")
+
+	db, err := gorm.Open(dialector, opts...)
+
+	if err != nil {
+		l.Error().Log(
+			"during", "gorm.Open",
+			"err", err,
+			"msg", fmt.Sprintf("error configuring database: %v", err),
+		)
+		return db, err
+	}
+
+	db.Logger = NewLogger(l)
+
+	l.Debug().Log("msg", "database good to go")
+	return db, nil
+}
@@ -0,0 +1,94 @@
+package gormv2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/theplant/appkit/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
+)
+
+// Logger is a `gorm.io/gorm/logger.Interface` that logs via a
+// `log.Logger`. If the context passed by gorm has a logger (see
+// `log.Context`), that logger is used instead of the default one.
+type Logger struct {
+	log.Logger
+	level logger.LogLevel
+}
+
+// NewLogger returns a Logger that will log all SQL queries.
+func NewLogger(l log.Logger) Logger {
+	return Logger{Logger: l, level: logger.Info}
+}
+
+// LogMode is part of logger.Interface
+func (l Logger) LogMode(level logger.LogLevel) logger.Interface {
+	l.level = level
+	return l
+}
+
+// Info is part of logger.Interface
+func (l Logger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Info {
+		l.logger(ctx).Info().Log("msg", fmt.Sprintf(msg, data...))
+	}
+}
+
+// Warn is part of logger.Interface
+func (l Logger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Warn {
+		l.logger(ctx).Warn().Log("msg", fmt.Sprintf(msg, data...))
+	}
+}
+
+// Error is part of logger.Interface
+func (l Logger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Error {
+		l.logger(ctx).Error().Log("msg", fmt.Sprintf(msg, data...))
+	}
+}
+
+// Trace is part of logger.Interface, it logs SQL queries with the
+// same levels as `log.GormLogger`: query errors are logged as
+// errors, queries slower than 100ms as warnings, slower than 50ms as
+// info, and everything else as debug.
+func (l Logger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+
+	dur := time.Since(begin)
+	sql, rows := fc()
+
+	lg := l.logger(ctx).With(
+		"type", "sql",
+		"source", utils.FileWithLineNum(),
+	)
+
+	args := []interface{}{"query_us", int64(dur / time.Microsecond), "query", sql}
+	if rows >= 0 {
+		args = append(args, "rows", rows)
+	}
+
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= logger.Error:
+		lg.Error().Log(append(args, "err", err, "msg", fmt.Sprintf("error executing query: %v", err))...)
+	case dur > 100*time.Millisecond && l.level >= logger.Warn:
+		lg.Warn().Log(args...)
+	case dur > 50*time.Millisecond && l.level >= logger.Info:
+		lg.Info().Log(args...)
+	case l.level >= logger.Info:
+		lg.Debug().Log(args...)
+	}
+}
+
+func (l Logger) logger(ctx context.Context) log.Logger {
+	if ctxLogger, ok := log.FromContext(ctx); ok {
+		return ctxLogger.With("context", "gorm")
+	}
+	return l.Logger
+}
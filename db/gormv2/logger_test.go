@@ -0,0 +1,83 @@
+package gormv2_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	klog "github.com/go-kit/kit/log"
+	"github.com/theplant/appkit/db/gormv2"
+	"github.com/theplant/appkit/log"
+	"gorm.io/gorm/logger"
+)
+
+func bufferLogger(output *bytes.Buffer) log.Logger {
+	return log.Logger{Logger: klog.LoggerFunc(func(keyvals ...interface{}) error {
+		fmt.Fprintln(output, keyvals...)
+		return nil
+	})}
+}
+
+func TestLoggerTrace(t *testing.T) {
+	query := func() (string, int64) { return "SELECT 1", 1 }
+
+	cases := []struct {
+		name     string
+		begin    time.Time
+		err      error
+		expected string
+	}{
+		{"fast query", time.Now(), nil, "level debug"},
+		{"slow query", time.Now().Add(-75 * time.Millisecond), nil, "level info"},
+		{"very slow query", time.Now().Add(-time.Second), nil, "level warn"},
+		{"query error", time.Now(), errors.New("broken"), "level error"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			output := bytes.NewBuffer(nil)
+			l := gormv2.NewLogger(bufferLogger(output))
+
+			l.Trace(context.Background(), c.begin, query, c.err)
+
+			if !strings.HasPrefix(output.String(), c.expected) {
+				t.Errorf("expected %q to start with %q", output.String(), c.expected)
+			}
+			if !strings.Contains(output.String(), "query SELECT 1 rows 1") {
+				t.Errorf("expected %q to include query", output.String())
+			}
+		})
+	}
+}
+
+func TestLoggerUsesContextLogger(t *testing.T) {
+	defaultOutput, ctxOutput := bytes.NewBuffer(nil), bytes.NewBuffer(nil)
+
+	l := gormv2.NewLogger(bufferLogger(defaultOutput))
+	ctx := log.Context(context.Background(), bufferLogger(ctxOutput).With("req_id", "abc"))
+
+	l.Info(ctx, "hello %s", "world")
+
+	if defaultOutput.Len() != 0 {
+		t.Errorf("expected nothing logged to default logger, got %q", defaultOutput.String())
+	}
+	if got, want := ctxOutput.String(), "level info req_id abc context gorm msg hello world\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestLoggerSilent(t *testing.T) {
+	output := bytes.NewBuffer(nil)
+	l := gormv2.NewLogger(bufferLogger(output)).LogMode(logger.Silent)
+
+	l.Error(context.Background(), "error")
+	l.Trace(context.Background(), time.Now(), func() (string, int64) { return "SELECT 1", 1 }, nil)
+
+	if output.Len() != 0 {
+		t.Errorf("expected no output, got %q", output.String())
+	}
+}
@@ -0,0 +1,98 @@
+package errornotifier
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/theplant/appkit/kerrs"
+	"github.com/theplant/appkit/log"
+)
+
+// NotifyOnCrit returns a logger that notifies n of every log at crit
+// level or above (see `log.Logger.Crit`) logged via the returned
+// logger, so that crit logs are reported without an explicit call to
+// `Notify`. Errors of panics already notified by `Recover` aren't
+// notified again.
+//
+// n is notified in a separate goroutine, and the log is written once
+// the notification is sent, or after a second at most, so that
+// notices of `Fatal` logs are usually sent before the program exits,
+// without a slow notifier blocking logging.
+func NotifyOnCrit(n Notifier, l log.Logger) log.Logger {
+	l.Logger = log.NewLevelHook(l.Logger, log.LevelCrit, func(keyvals []interface{}) {
+		if notified(keyvals) {
+			return
+		}
+
+		err := critError(keyvals)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			n.Notify(err, nil)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(critNotifyTimeout):
+		}
+	})
+	return l
+}
+
+// critNotifyTimeout is how long NotifyOnCrit waits for a notification
+// to be sent before writing the log.
+var critNotifyTimeout = time.Second
+
+// notified reports whether the `err` field of a log's keyvals has
+// already been notified, by `Recover`.
+func notified(keyvals []interface{}) bool {
+	for i := 1; i < len(keyvals); i += 2 {
+		if keyvals[i-1] != "err" {
+			continue
+		}
+		if err, ok := keyvals[i].(error); ok {
+			var n notifiedError
+			if errors.As(err, &n) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// critError builds an error from a log's keyvals, using the `err`
+// field as the cause (if any), `msg` as the message, and the other
+// fields as the error's context values.
+func critError(keyvals []interface{}) error {
+	var err error
+	var msg string
+	var kvs []interface{}
+
+	for i := 1; i < len(keyvals); i += 2 {
+		key, val := keyvals[i-1], keyvals[i]
+		switch key {
+		case "err":
+			if e, ok := val.(error); ok {
+				err = e
+				continue
+			}
+		case "msg":
+			msg = fmt.Sprint(val)
+			continue
+		case "level", "ts", "caller", "stacktrace":
+			continue
+		}
+		kvs = append(kvs, key, val)
+	}
+
+	if err != nil {
+		return kerrs.Wrapv(err, msg, kvs...)
+	}
+
+	err = errors.New(msg)
+	if len(kvs) == 0 {
+		return err
+	}
+	return kerrs.Wrapv(err, "", kvs...)
+}
@@ -0,0 +1,93 @@
+package errornotifier_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/theplant/appkit/errornotifier"
+	au "github.com/theplant/appkit/errornotifier/utils"
+	"github.com/theplant/appkit/log"
+	"github.com/theplant/appkit/server"
+)
+
+func TestNotifyOnCrit(t *testing.T) {
+	bufferNotifier := &au.BufferNotifier{}
+
+	l := errornotifier.NotifyOnCrit(bufferNotifier, log.NewNopLogger())
+
+	l.Error().Log("msg", "not notified")
+	l.With("order", 1).Crit().Log("msg", "payment failed", "err", errors.New("card declined"))
+	l.Fatal().Log("msg", "out of memory")
+	l.Crit().Log("msg", "disk full", "disk", "sda")
+
+	if len(bufferNotifier.Notices) != 3 {
+		t.Fatalf("Unexpected notices length, got %d.", len(bufferNotifier.Notices))
+	}
+
+	if got, want := bufferNotifier.Notices[0].Error.(error).Error(), "payment failed order=1: card declined"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if got, want := bufferNotifier.Notices[1].Error.(error).Error(), "out of memory"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if got, want := bufferNotifier.Notices[2].Error.(error).Error(), "disk=sda: disk full"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// blockingNotifier is a notifier that never finishes notifying, until
+// it's released.
+type blockingNotifier chan struct{}
+
+func (n blockingNotifier) Notify(interface{}, *http.Request) error {
+	<-n
+	return nil
+}
+
+func TestNotifyOnCritTimeout(t *testing.T) {
+	defer errornotifier.SetCritNotifyTimeout(errornotifier.SetCritNotifyTimeout(10 * time.Millisecond))
+
+	n := blockingNotifier(make(chan struct{}))
+	defer close(n)
+
+	var logged bool
+	l := errornotifier.NotifyOnCrit(n, log.Logger{Logger: kitlog.LoggerFunc(func(...interface{}) error {
+		logged = true
+		return nil
+	})})
+
+	done := make(chan struct{})
+	go func() {
+		l.Crit().Log("msg", "notifier down")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("crit log blocked by the notifier")
+	}
+	if !logged {
+		t.Error("crit log not written")
+	}
+}
+
+func TestNotifyOnCritRecoveredPanic(t *testing.T) {
+	bufferNotifier := &au.BufferNotifier{}
+	logger := errornotifier.NotifyOnCrit(bufferNotifier, log.NewNopLogger())
+
+	h := server.DefaultMiddleware(logger)(errornotifier.Recover(bufferNotifier)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(errHandlerException)
+	})))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if len(bufferNotifier.Notices) != 1 {
+		t.Fatalf("got %d notices, want 1", len(bufferNotifier.Notices))
+	}
+}
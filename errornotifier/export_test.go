@@ -0,0 +1,10 @@
+package errornotifier
+
+import "time"
+
+// SetCritNotifyTimeout sets how long NotifyOnCrit waits for
+// notifications, for tests of slow notifiers.
+func SetCritNotifyTimeout(d time.Duration) (previous time.Duration) {
+	previous, critNotifyTimeout = critNotifyTimeout, d
+	return previous
+}
@@ -24,12 +24,23 @@ func Recover(n Notifier) func(h http.Handler) http.Handler {
 				h.ServeHTTP(w, req.WithContext(c))
 			})
 			if err != nil {
-				panic(err)
+				// Mark the error as notified, so that `NotifyOnCrit`
+				// doesn't notify it again when the panic is logged
+				panic(notifiedError{err})
 			}
 		})
 	}
 }
 
+// notifiedError is an error that has already been notified.
+type notifiedError struct {
+	error
+}
+
+func (e notifiedError) Unwrap() error {
+	return e.error
+}
+
 // ForceContext extracts a notifier from the request context, falling
 // back to a LogNotifier using the context's logger.
 func ForceContext(c context.Context) Notifier {
@@ -0,0 +1,103 @@
+package kerrs
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrorCode is the kind of an error, used to decide how to handle it,
+// eg. which HTTP status to respond with.
+type ErrorCode string
+
+// Error codes, modelled after gRPC status codes
+const (
+	// Unknown is the code of errors without a code
+	Unknown          ErrorCode = "unknown"
+	InvalidArgument  ErrorCode = "invalid_argument"
+	NotFound         ErrorCode = "not_found"
+	Conflict         ErrorCode = "conflict"
+	Unauthenticated  ErrorCode = "unauthenticated"
+	PermissionDenied ErrorCode = "permission_denied"
+	// ResourceExhausted is for rate limits and quotas
+	ResourceExhausted ErrorCode = "resource_exhausted"
+	DeadlineExceeded  ErrorCode = "deadline_exceeded"
+	Internal          ErrorCode = "internal"
+	Unavailable       ErrorCode = "unavailable"
+)
+
+// codeKey is the key of the error code in the error's keyvals, so
+// that the code is also logged.
+const codeKey = "error_code"
+
+var httpStatuses = map[ErrorCode]int{
+	Unknown:           http.StatusInternalServerError,
+	InvalidArgument:   http.StatusBadRequest,
+	NotFound:          http.StatusNotFound,
+	Conflict:          http.StatusConflict,
+	Unauthenticated:   http.StatusUnauthorized,
+	PermissionDenied:  http.StatusForbidden,
+	ResourceExhausted: http.StatusTooManyRequests,
+	DeadlineExceeded:  http.StatusGatewayTimeout,
+	Internal:          http.StatusInternalServerError,
+	Unavailable:       http.StatusServiceUnavailable,
+}
+
+// HTTPStatus returns the HTTP status for errors with code c, 500 for
+// unknown codes.
+func (c ErrorCode) HTTPStatus() int {
+	if status, ok := httpStatuses[c]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+/*
+Wrapc is Wrapv that also attaches an error code to the error, that is returned by Code for the error and any error wrapping it.
+*/
+func Wrapc(err error, code ErrorCode, message string, keyvals ...interface{}) error {
+	return Wrapv(err, message, append([]interface{}{codeKey, code}, keyvals...)...)
+}
+
+/*
+Newc returns a new error with message and an error code, eg. `kerrs.Newc(kerrs.NotFound, "order not found", "order_code", code)`.
+*/
+func Newc(code ErrorCode, message string, keyvals ...interface{}) error {
+	return Wrapc(errors.New(message), code, "", keyvals...)
+}
+
+/*
+Code returns the code of an error, looking through the chain of wrapped errors. The outermost code wins, so an error can be wrapped with a different code. For multiple errors, the code of the first error with a code is returned. Errors without a code are Unknown, and nil errors have no code ("").
+*/
+func Code(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+
+	for err != nil {
+		if kver, ok := err.(keyvaluer); ok {
+			kvs := kver.Keyvals()
+			for i := 1; i < len(kvs); i += 2 {
+				if code, ok := kvs[i].(ErrorCode); ok && kvs[i-1] == codeKey {
+					return code
+				}
+			}
+		}
+
+		if errs, ok := branches(err); ok {
+			for _, e := range errs {
+				if code := Code(e); code != Unknown {
+					return code
+				}
+			}
+			break
+		}
+
+		next, ok := unwrap(err)
+		if !ok {
+			break
+		}
+		err = next
+	}
+
+	return Unknown
+}
@@ -0,0 +1,117 @@
+package kerrs
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxStackDepth is the maximum number of stack frames captured by
+// Wrapv
+const maxStackDepth = 32
+
+// kerror is the error returned by Wrapv: a message with context
+// values, wrapping a cause, with the stack of the Wrapv call.
+type kerror struct {
+	msg     string
+	keyvals []interface{}
+	cause   error
+
+	// stack is allocated with the error, to capture it with a single
+	// allocation
+	stack [maxStackDepth]uintptr
+	depth int
+}
+
+func newError(cause error, message string, keyvals []interface{}) *kerror {
+	e := &kerror{
+		msg:     message,
+		keyvals: keyvals,
+		cause:   cause,
+	}
+	// skip runtime.Callers and newError, so the stack starts at Wrapv
+	e.depth = runtime.Callers(2, e.stack[:])
+	return e
+}
+
+// Error returns the message, context values and cause's message, eg.
+// `loading order order_code=A1: record not found`.
+func (e *kerror) Error() string {
+	var b strings.Builder
+	b.WriteString(e.msg)
+
+	for i := 1; i < len(e.keyvals); i += 2 {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprint(&b, e.keyvals[i-1])
+		b.WriteByte('=')
+		b.WriteString(quoteValue(fmt.Sprint(e.keyvals[i])))
+	}
+
+	if b.Len() > 0 {
+		b.WriteString(": ")
+	}
+	b.WriteString(e.cause.Error())
+
+	return b.String()
+}
+
+// quoteValue quotes values with characters other than letters, digits
+// and common punctuation.
+func quoteValue(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_./:@+,", r) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+// Keyvals returns the context values of the error, without the
+// context values of its cause.
+func (e *kerror) Keyvals() []interface{} { return e.keyvals }
+
+// Cause is for compatibility with pkg/errors `Cause`.
+func (e *kerror) Cause() error { return e.cause }
+
+func (e *kerror) Unwrap() error { return e.cause }
+
+// StackTrace returns the frames of the stack where the error was
+// created.
+func (e *kerror) StackTrace() []runtime.Frame {
+	frames := runtime.CallersFrames(e.stack[:e.depth])
+	var r []runtime.Frame
+	for {
+		frame, more := frames.Next()
+		r = append(r, frame)
+		if !more {
+			break
+		}
+	}
+	return r
+}
+
+// Format formats the error like pkg/errors: `%s` and `%v` print the
+// message, `%+v` also prints the stack trace.
+func (e *kerror) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		io.WriteString(s, e.Error())
+		if s.Flag('+') {
+			for _, frame := range e.StackTrace() {
+				fmt.Fprintf(s, "\n%s\n\t%s:%d", frame.Function, frame.File, frame.Line)
+			}
+		}
+	case 's':
+		io.WriteString(s, e.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	}
+}
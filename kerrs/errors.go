@@ -13,18 +13,21 @@ import (
 	"strings"
 
 	merr "github.com/hashicorp/go-multierror"
-	jerrs "github.com/jjeffery/errors"
-	perrs "github.com/pkg/errors"
 )
 
 /*
 Wrapv should be invoked whenever an error returned from other libraries you imported, and you didn't handle the error, you should wrap it and return it to upper side. By wrapping it, includes stacktrace, and any context values, like your func parameters, So that when it gets logged, It reveal more contexts for developer to know where and what the problem is.
+
+The returned error unwraps to err, so `errors.Is` and `errors.As` work with wrapped errors. It formats like `pkg/errors` errors: `%v` and `%s` print the message, context values and wrapped error's message, `%+v` also prints the stack trace of the `Wrapv` call.
 */
 func Wrapv(err error, message string, keyvals ...interface{}) error {
+	if err == nil {
+		return nil
+	}
 	if len(keyvals)%2 == 1 {
 		keyvals = append(keyvals, "<value-missing>")
 	}
-	return perrs.WithStack(jerrs.With(keyvals...).Wrap(err, message))
+	return newError(err, message, keyvals)
 }
 
 /*
@@ -35,59 +38,128 @@ func Append(err error, errs ...error) error {
 	return merr.Append(err, errs...).ErrorOrNil()
 }
 
+type causer interface {
+	Cause() error
+}
+
+type keyvaluer interface {
+	Keyvals() []interface{}
+}
+
+type multiError interface {
+	Unwrap() []error
+}
+
+// unwrap returns the next error in err's chain, from pkg/errors
+// `Cause()` or go `Unwrap() error`.
+func unwrap(err error) (error, bool) {
+	switch e := err.(type) {
+	case causer:
+		return e.Cause(), true
+	case interface{ Unwrap() error }:
+		return e.Unwrap(), true
+	}
+	return nil, false
+}
+
+// branches returns the errors of an error tree node, from go
+// `Unwrap() []error` (eg. `errors.Join`) or go-multierror
+// `WrappedErrors()`.
+func branches(err error) ([]error, bool) {
+	switch e := err.(type) {
+	case multiError:
+		return e.Unwrap(), true
+	case interface{ WrappedErrors() []error }:
+		return e.WrappedErrors(), true
+	}
+	return nil, false
+}
+
 /*
 Extract an error of it's context values and message, it loop through to each level of errors, and concat each err message to a whole error message, and cause field is removed for easy to read, and concat each level error's stacktrace together to make a new whole stacktrace.
+
+Errors are unwrapped with pkg/errors `Cause()` and go `Unwrap() error`, so errors wrapped with `fmt.Errorf("...: %w", err)` are extracted too. For multiple errors (`Unwrap() []error`, eg. `errors.Join`, or `Append`), each error is extracted on its own, see SetMaxExtractedErrors. Stacktraces are rendered as configured by SetStacktrace.
 */
 func Extract(err error) (kvs []interface{}, msg string, stacktrace string) {
 	if err == nil {
 		return
 	}
 
-	var msgs []string
-	var stacktraces []string
-	type causer interface {
-		Cause() error
-	}
+	var x extraction
+	x.extract(err)
 
-	type keyvaluer interface {
-		Keyvals() []interface{}
-	}
+	msg = strings.Join(x.msgs, ": ")
+	stacktrace = strings.Join(x.stacktraces, "\n\n")
 
-	var lastMsg interface{}
+	return x.kvs, msg, stacktrace
+}
 
-	for err != nil {
-		lastMsg = err.Error()
-		cause, isCauser := err.(causer)
-		_, isKeyValuer := err.(keyvaluer)
+type extraction struct {
+	kvs         []interface{}
+	msgs        []string
+	stacktraces []string
 
-		if !isKeyValuer && isCauser {
-			stacktraces = append(stacktraces, fmt.Sprintf("%+v", err))
-		}
+	// stack of the last extracted error, to not repeat shared frames
+	stack []uintptr
+}
 
-		if !isCauser {
-			break
+func (x *extraction) extract(err error) {
+	for err != nil {
+		if errs, ok := branches(err); ok {
+			x.extractBranches(errs)
+			return
 		}
-		err = cause.Cause()
 
-		kver, isKeyValuer := err.(keyvaluer)
-		if isKeyValuer {
+		next, isWrapper := unwrap(err)
+		_, isCauser := err.(causer)
+
+		if _, ok := err.(*publicError); ok {
+			// public messages aren't part of the error's message
+		} else if kerr, ok := err.(*kerror); ok {
+			if kerr.msg != "" {
+				x.msgs = append(x.msgs, kerr.msg)
+			}
+			x.kvs = append(x.kvs, kerr.keyvals...)
+			x.stacktraces = append(x.stacktraces, stacktrace.Load().render(kerr, x.stack))
+			x.stack = kerr.stack[:kerr.depth]
+		} else if kver, isKeyValuer := err.(keyvaluer); isKeyValuer {
 			thekvs := kver.Keyvals()
 			for i := 1; i < len(thekvs); i += 2 {
 				key := thekvs[i-1]
 				val := thekvs[i]
 				if key == "msg" {
-					msgs = append(msgs, fmt.Sprintf("%+v", val))
-				} else if key == "cause" {
-					lastMsg = val
-				} else {
-					kvs = append(kvs, key, val)
+					if m := fmt.Sprintf("%+v", val); m != "" {
+						x.msgs = append(x.msgs, m)
+					}
+				} else if key != "cause" {
+					x.kvs = append(x.kvs, key, val)
 				}
 			}
+		} else if isCauser {
+			x.stacktraces = append(x.stacktraces, fmt.Sprintf("%+v", err))
+		} else if isWrapper && next != nil {
+			// eg. fmt.Errorf("loading order: %w", err)
+			if m := wrapperMessage(err, next); m != "" {
+				x.msgs = append(x.msgs, m)
+			}
 		}
+
+		if !isWrapper || next == nil {
+			x.msgs = append(x.msgs, err.Error())
+			return
+		}
+		err = next
 	}
-	msgs = append(msgs, fmt.Sprintf("%+v", lastMsg))
-	msg = strings.Join(msgs, ": ")
-	stacktrace = strings.Join(stacktraces, "\n\n")
+}
 
-	return
+// wrapperMessage returns the message added by a wrapping error, eg.
+// "loading order" for `fmt.Errorf("loading order: %w", next)`, or ""
+// if the wrapping error's message doesn't end with next's message.
+func wrapperMessage(err, next error) string {
+	m := err.Error()
+	nextMsg := next.Error()
+	if !strings.HasSuffix(m, nextMsg) {
+		return ""
+	}
+	return strings.TrimSuffix(strings.TrimSpace(strings.TrimSuffix(m, nextMsg)), ":")
 }
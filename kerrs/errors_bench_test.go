@@ -0,0 +1,48 @@
+package kerrs_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/theplant/appkit/kerrs"
+)
+
+var benchErr error
+
+func BenchmarkWrapv(b *testing.B) {
+	err := errors.New("record not found")
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		benchErr = kerrs.Wrapv(err, "loading order", "order_code", "A1", "user_id", 1)
+	}
+}
+
+func BenchmarkWrapvNested(b *testing.B) {
+	err := errors.New("record not found")
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		e := kerrs.Wrapv(err, "loading order", "order_code", "A1")
+		e = kerrs.Wrapv(e, "checking out", "cart_id", 2)
+		benchErr = kerrs.Wrapv(e, "handling request", "req_id", "abc")
+	}
+}
+
+func BenchmarkError(b *testing.B) {
+	err := kerrs.Wrapv(kerrs.Wrapv(errors.New("record not found"), "loading order", "order_code", "A1"), "checking out", "cart_id", 2)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = err.Error()
+	}
+}
+
+func BenchmarkExtract(b *testing.B) {
+	err := kerrs.Wrapv(kerrs.Wrapv(errors.New("record not found"), "loading order", "order_code", "A1"), "checking out", "cart_id", 2)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		kerrs.Extract(err)
+	}
+}
@@ -2,6 +2,10 @@ package kerrs_test
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/theplant/appkit/kerrs"
@@ -25,3 +29,295 @@ func TestExtractNormalError(t *testing.T) {
 		t.Error(diff)
 	}
 }
+
+func TestCode(t *testing.T) {
+	notFound := kerrs.Newc(kerrs.NotFound, "order not found", "order_code", "A1")
+
+	cases := []struct {
+		err      error
+		expected kerrs.ErrorCode
+	}{
+		{nil, ""},
+		{errors.New("hi"), kerrs.Unknown},
+		{notFound, kerrs.NotFound},
+		{kerrs.Wrapv(notFound, "loading order", "code", "not an error code"), kerrs.NotFound},
+		{kerrs.Wrapc(notFound, kerrs.InvalidArgument, "invalid order"), kerrs.InvalidArgument},
+	}
+
+	for _, c := range cases {
+		if got := kerrs.Code(c.err); got != c.expected {
+			t.Errorf("%v: got code %q, want %q", c.err, got, c.expected)
+		}
+	}
+
+	kvs, msg, _ := kerrs.Extract(kerrs.Wrapv(notFound, "loading order"))
+	if msg != "loading order: order not found" {
+		t.Errorf("got msg %q", msg)
+	}
+	diff := testingutils.PrettyJsonDiff([]interface{}{"error_code", "not_found", "order_code", "A1"}, kvs)
+	if len(diff) > 0 {
+		t.Error(diff)
+	}
+}
+
+type orderError struct{ code string }
+
+func (e *orderError) Error() string { return "invalid order " + e.code }
+
+func TestWrapvUnwrap(t *testing.T) {
+	err := kerrs.Wrapv(kerrs.Wrapv(io.EOF, "reading"), "loading order", "order_code", "A1")
+	if !errors.Is(err, io.EOF) {
+		t.Error("expected errors.Is to find io.EOF")
+	}
+
+	err = kerrs.Wrapv(&orderError{code: "A1"}, "loading order")
+	var oerr *orderError
+	if !errors.As(err, &oerr) || oerr.code != "A1" {
+		t.Errorf("expected errors.As to find *orderError, got %v", oerr)
+	}
+}
+
+func TestExtractWrappedErrors(t *testing.T) {
+	err := kerrs.Wrapv(io.EOF, "reading", "file", "orders.csv")
+	err = fmt.Errorf("importing orders: %w", err)
+	err = kerrs.Wrapc(err, kerrs.InvalidArgument, "handling upload", "user_id", 1)
+
+	kvs, msg, stacktrace := kerrs.Extract(err)
+	if msg != "handling upload: importing orders: reading: EOF" {
+		t.Errorf("got msg %q", msg)
+	}
+	diff := testingutils.PrettyJsonDiff([]interface{}{"error_code", "invalid_argument", "user_id", 1, "file", "orders.csv"}, kvs)
+	if len(diff) > 0 {
+		t.Error(diff)
+	}
+	if got := len(strings.Split(stacktrace, "\n\n")); got != 2 {
+		t.Errorf("expected 2 stacktraces, got %d:\n%s", got, stacktrace)
+	}
+
+	if kerrs.Code(fmt.Errorf("wrapped: %w", err)) != kerrs.InvalidArgument {
+		t.Error("expected code through fmt.Errorf wrapping")
+	}
+}
+
+func TestExtractErrorTree(t *testing.T) {
+	err := errors.Join(
+		kerrs.Wrapv(io.EOF, "reading", "line", 1),
+		kerrs.Newc(kerrs.NotFound, "product not found", "sku", "X Y"),
+	)
+	err = kerrs.Wrapv(err, "importing orders", "file", "orders.csv")
+
+	kvs, msg, stacktrace := kerrs.Extract(err)
+	if msg != "importing orders: 2 errors occurred: reading: EOF; product not found" {
+		t.Errorf("got msg %q", msg)
+	}
+	diff := testingutils.PrettyJsonDiff([]interface{}{
+		"file", "orders.csv",
+		"error_count", 2,
+		"error.0", "reading: EOF line=1",
+		"error.1", `product not found error_code=not_found sku="X Y"`,
+	}, kvs)
+	if len(diff) > 0 {
+		t.Error(diff)
+	}
+	if got := len(strings.Split(stacktrace, "\n\n")); got != 3 {
+		t.Errorf("expected 3 stacktraces, got %d:\n%s", got, stacktrace)
+	}
+
+	if !errors.Is(err, io.EOF) {
+		t.Error("expected errors.Is to find io.EOF in tree")
+	}
+	if kerrs.Code(err) != kerrs.NotFound {
+		t.Errorf("got code %q", kerrs.Code(err))
+	}
+}
+
+func TestExtractMaxErrors(t *testing.T) {
+	defer kerrs.SetMaxExtractedErrors(kerrs.SetMaxExtractedErrors(2))
+
+	var err error
+	for i := 1; i <= 4; i++ {
+		err = kerrs.Append(err, kerrs.Wrapv(fmt.Errorf("invalid line %d", i), "", "line", i))
+	}
+
+	if got := kerrs.Count(err); got != 4 {
+		t.Errorf("got count %d, want 4", got)
+	}
+	if got := kerrs.Count(kerrs.Wrapv(err, "importing")); got != 4 {
+		t.Errorf("got count %d through wrapping, want 4", got)
+	}
+	if got := kerrs.Count(io.EOF); got != 1 {
+		t.Errorf("got count %d for single error, want 1", got)
+	}
+	if got := kerrs.Count(nil); got != 0 {
+		t.Errorf("got count %d for nil, want 0", got)
+	}
+
+	kvs, msg, _ := kerrs.Extract(err)
+	if msg != "4 errors occurred: invalid line 1; invalid line 2 (and 2 more)" {
+		t.Errorf("got msg %q", msg)
+	}
+	diff := testingutils.PrettyJsonDiff([]interface{}{
+		"error_count", 4,
+		"error.0", "invalid line 1 line=1",
+		"error.1", "invalid line 2 line=2",
+		"errors_omitted", 2,
+	}, kvs)
+	if len(diff) > 0 {
+		t.Error(diff)
+	}
+
+	for i, e := range kerrs.Errors(err) {
+		if want := fmt.Sprintf("invalid line %d", i+1); !strings.HasSuffix(e.Error(), want) {
+			t.Errorf("got error %d %q, want %q", i, e, want)
+		}
+	}
+
+	// zero renders all errors
+	kerrs.SetMaxExtractedErrors(0)
+	kvs, _, _ = kerrs.Extract(err)
+	if len(kvs) != 10 {
+		t.Errorf("got %v with no maximum, want the count and 4 errors", kvs)
+	}
+}
+
+func TestWrapvFormat(t *testing.T) {
+	err := kerrs.Wrapv(errors.New("hi"), "", "path", "a b", "n", 1)
+	if got, want := err.Error(), `path="a b" n=1: hi`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := kerrs.Wrapv(errors.New("hi"), ""); got.Error() != "hi" {
+		t.Errorf("got %q, want %q", got, "hi")
+	}
+	if kerrs.Wrapv(nil, "wrap message") != nil {
+		t.Error("expected wrapping nil to return nil")
+	}
+
+	err = kerrs.Wrapv(errors.New("hi"), "wrap message")
+	if got := fmt.Sprintf("%v|%s|%q", err, err, err); got != `wrap message: hi|wrap message: hi|"wrap message: hi"` {
+		t.Errorf("got %q", got)
+	}
+
+	lines := strings.Split(fmt.Sprintf("%+v", err), "\n")
+	// like pkg/errors, the stack starts at Wrapv
+	if len(lines) < 5 || lines[0] != "wrap message: hi" ||
+		lines[1] != "github.com/theplant/appkit/kerrs.Wrapv" ||
+		lines[3] != "github.com/theplant/appkit/kerrs_test.TestWrapvFormat" ||
+		!strings.HasPrefix(lines[4], "\t") || !strings.Contains(lines[4], "errors_test.go:") {
+		t.Errorf("unexpected stack trace format:\n%+v", err)
+	}
+}
+
+func TestPublic(t *testing.T) {
+	err := kerrs.Invalid("Invalid order", kerrs.FieldError{Field: "email", Message: "is required"})
+	err = kerrs.Append(err, kerrs.WithPublic(
+		kerrs.Wrapv(errors.New("bad zip"), "validating address", "zip", "1"),
+		"", kerrs.FieldError{Field: "zip", Message: "must be 5 digits"},
+	))
+	err = kerrs.Wrapv(err, "placing order", "order_code", "A1")
+
+	public := kerrs.ToPublic(err)
+	diff := testingutils.PrettyJsonDiff(kerrs.PublicError{
+		Code:    kerrs.InvalidArgument,
+		Message: "Invalid order",
+		Details: []kerrs.FieldError{
+			{Field: "email", Message: "is required"},
+			{Field: "zip", Message: "must be 5 digits"},
+		},
+	}, public)
+	if len(diff) > 0 {
+		t.Error(diff)
+	}
+
+	// internal details are still logged
+	_, msg, _ := kerrs.Extract(err)
+	if msg != "placing order: 2 errors occurred: Invalid order; validating address: bad zip" {
+		t.Errorf("got msg %q", msg)
+	}
+
+	public = kerrs.ToPublic(kerrs.Wrapv(errors.New("connection refused"), "connecting to db"))
+	if public.Code != kerrs.Unknown || public.Message != "Internal Server Error" || public.Details != nil {
+		t.Errorf("got %+v", public)
+	}
+}
+
+func loadOrder() error {
+	return kerrs.Wrapv(io.EOF, "loading order")
+}
+
+func placeOrder() error {
+	return kerrs.Wrapv(loadOrder(), "placing order")
+}
+
+func TestExtractStacktrace(t *testing.T) {
+	_, _, stacktrace := kerrs.Extract(placeOrder())
+
+	if strings.Contains(stacktrace, "kerrs.Wrapv") || strings.Contains(stacktrace, "testing.tRunner") {
+		t.Errorf("expected kerrs and standard library frames to be filtered:\n%s", stacktrace)
+	}
+	if got := strings.Count(stacktrace, "kerrs_test.TestExtractStacktrace"); got != 1 {
+		t.Errorf("expected shared frames once, got %d times:\n%s", got, stacktrace)
+	}
+
+	traces := strings.Split(stacktrace, "\n\n")
+	if len(traces) != 2 {
+		t.Fatalf("expected 2 stacktraces, got:\n%s", stacktrace)
+	}
+	if lines := strings.Split(traces[1], "\n"); lines[0] != "loading order: EOF" ||
+		lines[1] != "github.com/theplant/appkit/kerrs_test.loadOrder" ||
+		lines[3] != "github.com/theplant/appkit/kerrs_test.placeOrder" ||
+		!strings.HasPrefix(lines[len(lines)-1], "\t... ") {
+		t.Errorf("unexpected stacktrace:\n%s", traces[1])
+	}
+}
+
+func TestExtractStacktraceConfig(t *testing.T) {
+	defer kerrs.SetStacktrace(kerrs.SetStacktrace(kerrs.StacktraceConfig{
+		MaxDepth:      1,
+		ModulePath:    "github.com/theplant/appkit",
+		RepositoryURL: "https://github.com/theplant/appkit/",
+		Revision:      "abc123",
+	}))
+
+	_, _, stacktrace := kerrs.Extract(loadOrder())
+	lines := strings.Split(stacktrace, "\n")
+	if len(lines) != 4 ||
+		lines[1] != "github.com/theplant/appkit/kerrs_test.loadOrder" ||
+		!strings.HasPrefix(lines[2], "\thttps://github.com/theplant/appkit/blob/abc123/kerrs/errors_test.go#L") ||
+		!strings.HasPrefix(lines[3], "\t... ") {
+		t.Errorf("unexpected stacktrace:\n%s", stacktrace)
+	}
+
+	kerrs.SetStacktrace(kerrs.StacktraceConfig{KeepAllFrames: true})
+	_, _, stacktrace = kerrs.Extract(loadOrder())
+	if !strings.Contains(stacktrace, "kerrs.Wrapv") || !strings.Contains(stacktrace, "testing.tRunner") {
+		t.Errorf("expected all frames:\n%s", stacktrace)
+	}
+}
+
+func TestStacktraceFilteredFrames(t *testing.T) {
+	cases := []struct {
+		function, file string
+		filtered       bool
+	}{
+		{"main.handler", "/home/app/main.go", false},
+		{"main.main", "app/main.go", false},
+		{"myapp/handlers.(*Orders).Get", "/home/myapp/handlers/orders.go", false},
+		{"example.com/net.Dial", "/home/net/dial.go", false},
+		{"github.com/theplant/appkit/server.Recovery", "/go/src/github.com/theplant/appkit/server/recover.go", false},
+		{"net/http.HandlerFunc.ServeHTTP", "/usr/local/go/src/net/http/server.go", true},
+		{"runtime.goexit", "/usr/local/go/src/runtime/asm_amd64.s", true},
+		// GOROOT of another machine
+		{"runtime.goexit", "/opt/go1.22/src/runtime/asm_amd64.s", true},
+		// built with -trimpath
+		{"runtime.goexit", "runtime/asm_amd64.s", true},
+		{"github.com/x/y/vendor/github.com/z/w.F", "/src/y/vendor/github.com/z/w/w.go", true},
+		{"github.com/theplant/appkit/kerrs.Wrapv", "/src/appkit/kerrs/error.go", true},
+	}
+
+	for _, c := range cases {
+		frame := runtime.Frame{Function: c.function, File: c.file}
+		if got := kerrs.FilteredFrame(frame); got != c.filtered {
+			t.Errorf("%s (%s): got filtered %v, want %v", c.function, c.file, got, c.filtered)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+package kerrs
+
+// FilteredFrame exposes filteredFrame to tests, for frames of
+// programs that can't be built by tests.
+var FilteredFrame = filteredFrame
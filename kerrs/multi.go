@@ -0,0 +1,106 @@
+package kerrs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+/*
+SetMaxExtractedErrors sets the maximum number of errors of a multiple error that Extract renders, 10 by default, and returns the previous maximum. Call it before use, eg. in main, it's safe to call concurrently with Extract though. A multiple error is extracted as:
+
+- `error_count`: the number of errors
+- `error.0`, `error.1`, ...: each error's message and context values, eg. `reading: EOF line=12`
+- `errors_omitted`: the number of errors not rendered, if there are more than the maximum
+
+The message lists the messages of the rendered errors, and the stacktrace includes their stacktraces. Zero or a negative value renders all errors.
+*/
+func SetMaxExtractedErrors(n int) (previous int) {
+	return int(maxExtractedErrors.Swap(int64(n)))
+}
+
+var maxExtractedErrors atomic.Int64
+
+func init() {
+	maxExtractedErrors.Store(10)
+}
+
+/*
+Errors returns the errors of a multiple error (returned by Append, or any error with `Unwrap() []error`, like `errors.Join`), looking through wrapped errors. A single error is returned as a one error slice, and nil as an empty slice.
+*/
+func Errors(err error) []error {
+	if err == nil {
+		return nil
+	}
+
+	for e := err; e != nil; {
+		if errs, ok := branches(e); ok {
+			return errs
+		}
+
+		next, ok := unwrap(e)
+		if !ok {
+			break
+		}
+		e = next
+	}
+
+	return []error{err}
+}
+
+/*
+Count returns the number of errors in err, see Errors.
+*/
+func Count(err error) int {
+	return len(Errors(err))
+}
+
+// extractBranches extracts each of errs on its own, up to the
+// maximum set by SetMaxExtractedErrors.
+func (x *extraction) extractBranches(errs []error) {
+	x.kvs = append(x.kvs, "error_count", len(errs))
+
+	shown := errs
+	if max := int(maxExtractedErrors.Load()); max > 0 && len(errs) > max {
+		shown = errs[:max]
+	}
+
+	var msgs []string
+	for i, e := range shown {
+		bx := extraction{stack: x.stack}
+		bx.extract(e)
+
+		msg := strings.Join(bx.msgs, ": ")
+		msgs = append(msgs, msg)
+		x.kvs = append(x.kvs, fmt.Sprintf("error.%d", i), renderError(msg, bx.kvs))
+		x.stacktraces = append(x.stacktraces, bx.stacktraces...)
+	}
+
+	msg := fmt.Sprintf("%d errors occurred", len(errs))
+	if len(errs) == 1 {
+		msg = "1 error occurred"
+	}
+	if len(msgs) > 0 {
+		msg += ": " + strings.Join(msgs, "; ")
+	}
+	if omitted := len(errs) - len(shown); omitted > 0 {
+		x.kvs = append(x.kvs, "errors_omitted", omitted)
+		msg += fmt.Sprintf(" (and %d more)", omitted)
+	}
+	x.msgs = append(x.msgs, msg)
+}
+
+// renderError renders an error's message and context values on one
+// line, eg. `reading: EOF line=12`.
+func renderError(msg string, kvs []interface{}) string {
+	parts := []string{msg}
+	for i := 1; i < len(kvs); i += 2 {
+		val := fmt.Sprint(kvs[i])
+		if strings.ContainsAny(val, " =\"") {
+			val = strconv.Quote(val)
+		}
+		parts = append(parts, fmt.Sprintf("%v=%s", kvs[i-1], val))
+	}
+	return strings.Join(parts, " ")
+}
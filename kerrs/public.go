@@ -0,0 +1,136 @@
+package kerrs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// FieldError is a public validation error of a field, eg. `{"field":
+// "email", "message": "is not a valid email address"}`.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// publicError adds a public message and details to an error, without
+// changing its message or context values.
+type publicError struct {
+	cause   error
+	message string
+	details []FieldError
+}
+
+func (e *publicError) Error() string { return e.cause.Error() }
+
+// Cause is for compatibility with pkg/errors `Cause`.
+func (e *publicError) Cause() error { return e.cause }
+
+func (e *publicError) Unwrap() error { return e.cause }
+
+func (e *publicError) Format(s fmt.State, verb rune) {
+	if f, ok := e.cause.(fmt.Formatter); ok {
+		f.Format(s, verb)
+		return
+	}
+	fmt.Fprintf(s, fmt.FormatString(s, verb), e.cause)
+}
+
+/*
+WithPublic adds a public message, and optional public details, to an error. The public message is safe to show to users, eg. in HTTP responses (see ToPublic), unlike the error's message, that includes internal details of every wrapped error. The error's message, context values and stacktrace are unchanged, so logs get the full error.
+*/
+func WithPublic(err error, message string, details ...FieldError) error {
+	if err == nil {
+		return nil
+	}
+	return &publicError{cause: err, message: message, details: details}
+}
+
+/*
+Invalid returns a new InvalidArgument error, with message as its public message, and field errors as public details, eg.
+
+	kerrs.Invalid("invalid address", kerrs.FieldError{Field: "zip", Message: "must be 5 digits"})
+*/
+func Invalid(message string, details ...FieldError) error {
+	e := newError(errors.New(message), "", []interface{}{codeKey, InvalidArgument})
+	return &publicError{cause: e, message: message, details: details}
+}
+
+/*
+PublicMessage returns the public message of an error, looking through the chain of wrapped errors. The outermost public message wins. Returns "" if the error has no public message.
+*/
+func PublicMessage(err error) string {
+	var message string
+	walk(err, func(e error) bool {
+		if pe, ok := e.(*publicError); ok && pe.message != "" {
+			message = pe.message
+			return false
+		}
+		return true
+	})
+	return message
+}
+
+/*
+Details returns the public details of an error, and all errors it wraps, including each error of multiple errors.
+*/
+func Details(err error) []FieldError {
+	var details []FieldError
+	walk(err, func(e error) bool {
+		if pe, ok := e.(*publicError); ok {
+			details = append(details, pe.details...)
+		}
+		return true
+	})
+	return details
+}
+
+// PublicError is the public form of an error, safe to show to users.
+type PublicError struct {
+	Code    ErrorCode    `json:"code"`
+	Message string       `json:"message"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+/*
+ToPublic returns the public form of an error: its code, public message and details. Errors without a public message get the text of the code's HTTP status, eg. "Internal Server Error", so that internal details never reach users.
+*/
+func ToPublic(err error) PublicError {
+	code := Code(err)
+	message := PublicMessage(err)
+	if message == "" {
+		message = http.StatusText(code.HTTPStatus())
+	}
+
+	return PublicError{
+		Code:    code,
+		Message: message,
+		Details: Details(err),
+	}
+}
+
+// walk calls f for err and every error it wraps, depth first, until
+// f returns false. It returns false if walking was stopped.
+func walk(err error, f func(error) bool) bool {
+	for err != nil {
+		if !f(err) {
+			return false
+		}
+
+		if errs, ok := branches(err); ok {
+			for _, e := range errs {
+				if !walk(e, f) {
+					return false
+				}
+			}
+			return true
+		}
+
+		next, ok := unwrap(err)
+		if !ok {
+			return true
+		}
+		err = next
+	}
+	return true
+}
@@ -0,0 +1,192 @@
+package kerrs
+
+import (
+	"fmt"
+	"path"
+	"runtime"
+	"strings"
+	"sync/atomic"
+)
+
+/*
+StacktraceConfig configures how Extract renders the stacktraces of errors.
+*/
+type StacktraceConfig struct {
+	// MaxDepth is the maximum number of frames rendered for each error,
+	// 0 renders all frames.
+	MaxDepth int
+
+	// KeepAllFrames keeps frames that are filtered by default: frames
+	// of the go runtime and standard library, of vendored packages, and
+	// of kerrs itself.
+	KeepAllFrames bool
+
+	// ModulePath, RepositoryURL and Revision render frames of the
+	// module as links to the source code in the repository, eg.
+	// "github.com/theplant/appkit", "https://github.com/theplant/appkit"
+	// and the git commit the program was built from.
+	ModulePath    string
+	RepositoryURL string
+	Revision      string
+
+	// LinkFormat is the format of links, with the repository URL,
+	// revision, path of the file in the repository and line as
+	// arguments. Defaults to GitHub's "%s/blob/%s/%s#L%d".
+	LinkFormat string
+}
+
+/*
+SetStacktrace configures how Extract renders the stacktraces of errors, and returns the previous configuration. Call it before use, eg. in main, it's safe to call concurrently with Extract though.
+
+Frames shared with the stacktrace of the previous (wrapping) error are rendered once, and frames of the go runtime and standard library, of vendored packages and of kerrs itself are filtered, unless KeepAllFrames is set.
+*/
+func SetStacktrace(c StacktraceConfig) (previous StacktraceConfig) {
+	return *stacktrace.Swap(&c)
+}
+
+var stacktrace atomic.Pointer[StacktraceConfig]
+
+func init() {
+	stacktrace.Store(&StacktraceConfig{})
+}
+
+const defaultLinkFormat = "%s/blob/%s/%s#L%d"
+
+// render renders the message and stacktrace of err, without the
+// frames shared with previous, the stack of the error wrapping err.
+func (c StacktraceConfig) render(err *kerror, previous []uintptr) string {
+	stack := err.stack[:err.depth]
+
+	shared := 0
+	for shared < len(stack) && shared < len(previous) &&
+		stack[len(stack)-1-shared] == previous[len(previous)-1-shared] {
+		shared++
+	}
+	stack = stack[:len(stack)-shared]
+
+	var b strings.Builder
+	b.WriteString(err.Error())
+
+	rendered := 0
+	omitted := 0
+	if len(stack) > 0 {
+		frames := runtime.CallersFrames(stack)
+		for {
+			frame, more := frames.Next()
+			if c.KeepAllFrames || !filteredFrame(frame) {
+				if c.MaxDepth > 0 && rendered >= c.MaxDepth {
+					omitted++
+				} else {
+					b.WriteString(c.frame(frame))
+					rendered++
+				}
+			}
+			if !more {
+				break
+			}
+		}
+	}
+
+	if omitted > 0 {
+		fmt.Fprintf(&b, "\n\t... %d more frames", omitted)
+	}
+	if shared > 0 {
+		fmt.Fprintf(&b, "\n\t... %d frames shared with the previous stacktrace", shared)
+	}
+
+	return b.String()
+}
+
+func (c StacktraceConfig) frame(frame runtime.Frame) string {
+	if link, ok := c.link(frame); ok {
+		return fmt.Sprintf("\n%s\n\t%s", frame.Function, link)
+	}
+	return fmt.Sprintf("\n%s\n\t%s:%d", frame.Function, frame.File, frame.Line)
+}
+
+// link returns a link to the frame's source in the repository, for
+// frames of the module.
+func (c StacktraceConfig) link(frame runtime.Frame) (string, bool) {
+	if c.ModulePath == "" || c.RepositoryURL == "" || c.Revision == "" {
+		return "", false
+	}
+
+	// external test packages are in the directory of the package
+	pkg := strings.TrimSuffix(framePackage(frame), "_test")
+	if pkg != c.ModulePath && !strings.HasPrefix(pkg, c.ModulePath+"/") {
+		return "", false
+	}
+
+	// The file's directory is derived from the package, as frame.File
+	// is the path on the machine that built the program
+	dir := strings.TrimPrefix(strings.TrimPrefix(pkg, c.ModulePath), "/")
+	file := path.Join(dir, path.Base(frame.File))
+
+	format := c.LinkFormat
+	if format == "" {
+		format = defaultLinkFormat
+	}
+	return fmt.Sprintf(format, strings.TrimSuffix(c.RepositoryURL, "/"), c.Revision, file, frame.Line), true
+}
+
+// framePackage returns the import path of the frame's package, eg.
+// "github.com/theplant/appkit/kerrs" for
+// "github.com/theplant/appkit/kerrs.(*kerror).Error".
+func framePackage(frame runtime.Frame) string {
+	fn := frame.Function
+	slash := strings.LastIndex(fn, "/")
+	if dot := strings.Index(fn[slash+1:], "."); dot >= 0 {
+		return fn[:slash+1+dot]
+	}
+	return fn
+}
+
+// filteredFrame reports whether frame is of the go runtime or
+// standard library, of a vendored package, or of kerrs. Frames of
+// `main` packages are never filtered.
+func filteredFrame(frame runtime.Frame) bool {
+	pkg := framePackage(frame)
+	if pkg == "main" {
+		return false
+	}
+
+	if stdlibPackage(pkg) {
+		return true
+	}
+
+	if strings.Contains(frame.File, "/vendor/") {
+		return true
+	}
+
+	return pkg == kerrsPackage
+}
+
+// stdlibPackage reports whether pkg is of the go runtime or standard
+// library: the first element of its path (that has no dot) is a
+// top-level directory of the standard library. The package path is
+// used instead of the frame's file, that depends on the GOROOT of the
+// machine that built the program, and on `-trimpath`. Modules without
+// a dot in their path, eg. "myapp", aren't standard library.
+func stdlibPackage(pkg string) bool {
+	return stdlibRoots[strings.SplitN(pkg, "/", 2)[0]]
+}
+
+// stdlibRoots are the top-level directories of the standard library
+var stdlibRoots = map[string]bool{}
+
+func init() {
+	for _, root := range strings.Fields(`
+		archive bufio bytes cmp compress container context crypto
+		database debug embed encoding errors expvar flag fmt go hash
+		html image index internal io iter log maps math mime net os
+		path plugin reflect regexp runtime slices sort strconv strings
+		sync syscall testing text time unicode unique unsafe weak
+	`) {
+		stdlibRoots[root] = true
+	}
+}
+
+var kerrsPackage = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	return framePackage(runtime.Frame{Function: runtime.FuncForPC(pc).Name()})
+}()
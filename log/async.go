@@ -0,0 +1,224 @@
+package log
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AsyncPolicy is what an AsyncWriter does when its buffer is full.
+type AsyncPolicy int
+
+const (
+	// AsyncBlock blocks writes until there is space in the buffer, so
+	// no logs are lost, but logging slows down to the speed of the
+	// underlying writer.
+	AsyncBlock AsyncPolicy = iota
+
+	// AsyncDrop drops writes when the buffer is full, so logging never
+	// blocks. Dropped lines are counted by `AsyncWriter.Dropped`.
+	AsyncDrop
+)
+
+// AsyncConfig configures an AsyncWriter. Zero values are replaced by
+// the defaults noted for each field.
+type AsyncConfig struct {
+	// BufferSize is the maximum number of lines waiting to be written.
+	// Defaults to 1024.
+	BufferSize int
+
+	// Policy is what to do when the buffer is full. Defaults to
+	// AsyncBlock.
+	Policy AsyncPolicy
+
+	// FlushInterval is how often written lines are flushed to the
+	// underlying writer. Defaults to 1s.
+	FlushInterval time.Duration
+}
+
+// ErrAsyncWriterClosed is returned when writing to a closed
+// AsyncWriter.
+var ErrAsyncWriterClosed = errors.New("log: write to closed AsyncWriter")
+
+// AsyncWriter is an io.Writer that writes to an underlying writer in
+// a separate goroutine, so that logging doesn't wait for a lock and a
+// syscall on every line. Lines are queued in a bounded ring buffer,
+// and written in batches through a buffered writer that is flushed
+// periodically.
+//
+// `Flush` or `Close` must be called before the program exits,
+// otherwise buffered lines are lost.
+type AsyncWriter struct {
+	config AsyncConfig
+	out    *bufio.Writer
+
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	ring     [][]byte
+	head     int
+	size     int
+	flushes  []chan error
+	closed   bool
+
+	dropped uint64
+	written uint64
+
+	done chan struct{}
+}
+
+// NewAsyncWriter returns an AsyncWriter writing to w, and starts its
+// writing goroutine.
+func NewAsyncWriter(w io.Writer, config AsyncConfig) *AsyncWriter {
+	if config.BufferSize <= 0 {
+		config.BufferSize = 1024
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+
+	aw := &AsyncWriter{
+		config: config,
+		out:    bufio.NewWriter(w),
+		ring:   make([][]byte, config.BufferSize),
+		done:   make(chan struct{}),
+	}
+	aw.notEmpty = sync.NewCond(&aw.mu)
+	aw.notFull = sync.NewCond(&aw.mu)
+
+	go aw.run()
+	go aw.tick()
+
+	return aw
+}
+
+// Write queues a copy of p to be written. It never returns an error
+// from the underlying writer.
+func (w *AsyncWriter) Write(p []byte) (int, error) {
+	line := append([]byte(nil), p...)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for w.size == len(w.ring) && !w.closed {
+		if w.config.Policy == AsyncDrop {
+			atomic.AddUint64(&w.dropped, 1)
+			return len(p), nil
+		}
+		w.notFull.Wait()
+	}
+
+	if w.closed {
+		return 0, ErrAsyncWriterClosed
+	}
+
+	w.ring[(w.head+w.size)%len(w.ring)] = line
+	w.size++
+	w.notEmpty.Signal()
+
+	return len(p), nil
+}
+
+// Flush waits until all lines queued before the call are written and
+// flushed to the underlying writer.
+func (w *AsyncWriter) Flush() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	done := make(chan error, 1)
+	w.flushes = append(w.flushes, done)
+	w.notEmpty.Signal()
+	w.mu.Unlock()
+
+	return <-done
+}
+
+// Close flushes any queued lines, and stops the writer. Writes after
+// Close return ErrAsyncWriterClosed.
+func (w *AsyncWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.notEmpty.Signal()
+	w.notFull.Broadcast()
+	w.mu.Unlock()
+
+	<-w.done
+	return w.out.Flush()
+}
+
+// Dropped returns the number of lines dropped because the buffer was
+// full (only with AsyncDrop policy).
+func (w *AsyncWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// Written returns the number of lines written to the underlying
+// writer.
+func (w *AsyncWriter) Written() uint64 {
+	return atomic.LoadUint64(&w.written)
+}
+
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+
+	var batch [][]byte
+	for {
+		w.mu.Lock()
+		for w.size == 0 && len(w.flushes) == 0 && !w.closed {
+			w.notEmpty.Wait()
+		}
+
+		batch = batch[:0]
+		for ; w.size > 0; w.size-- {
+			batch = append(batch, w.ring[w.head])
+			w.ring[w.head] = nil
+			w.head = (w.head + 1) % len(w.ring)
+		}
+		flushes := w.flushes
+		w.flushes = nil
+		closed := w.closed
+		w.notFull.Broadcast()
+		w.mu.Unlock()
+
+		for _, line := range batch {
+			// Errors can't be reported to the logger, so the best we can
+			// do is to keep writing
+			w.out.Write(line)
+		}
+		atomic.AddUint64(&w.written, uint64(len(batch)))
+
+		if len(flushes) > 0 {
+			err := w.out.Flush()
+			for _, done := range flushes {
+				done <- err
+			}
+		}
+
+		if closed {
+			return
+		}
+	}
+}
+
+func (w *AsyncWriter) tick() {
+	ticker := time.NewTicker(w.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.Flush()
+		case <-w.done:
+			return
+		}
+	}
+}
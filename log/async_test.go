@@ -0,0 +1,82 @@
+package log_test
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/theplant/appkit/log"
+)
+
+// blockingWriter blocks all writes until unblocked
+type blockingWriter struct {
+	bytes.Buffer
+	unblock chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.unblock
+	return w.Buffer.Write(p)
+}
+
+func TestAsyncWriterFlush(t *testing.T) {
+	output := bytes.NewBuffer(nil)
+	w := log.NewAsyncWriter(output, log.AsyncConfig{FlushInterval: time.Hour})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fmt.Fprintf(w, "line %d\n", i)
+		}(i)
+	}
+	wg.Wait()
+
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := bytes.Count(output.Bytes(), []byte("\n")); got != 10 {
+		t.Errorf("got %d lines after flush, want 10", got)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.Write([]byte("late\n")); err != log.ErrAsyncWriterClosed {
+		t.Errorf("got %v writing to closed writer", err)
+	}
+}
+
+func TestAsyncWriterDrop(t *testing.T) {
+	output := &blockingWriter{unblock: make(chan struct{})}
+	w := log.NewAsyncWriter(output, log.AsyncConfig{
+		BufferSize: 2,
+		Policy:     log.AsyncDrop,
+		// bufio buffers the first writes, so make sure the writer
+		// actually blocks.
+		FlushInterval: time.Millisecond,
+	})
+
+	// Give the writer time to pick up the first lines and block on
+	// the underlying writer, then fill the buffer
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(w, "line %d\n", i)
+		time.Sleep(100 * time.Microsecond)
+	}
+
+	if w.Dropped() == 0 {
+		t.Error("expected dropped lines")
+	}
+
+	close(output.unblock)
+	w.Close()
+
+	if got, want := w.Written()+w.Dropped(), uint64(100); got != want {
+		t.Errorf("got %d written + dropped lines, want %d", got, want)
+	}
+}
@@ -3,49 +3,161 @@ package log
 import (
 	"context"
 	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
 
 	"github.com/theplant/appkit/contexts/trace"
 )
 
 type key int
 
-const loggerKey key = iota
+const (
+	loggerKey key = iota
+	fieldsKey
+)
+
+const warnFallbackLogEnvName = "APPKIT_LOG_WARN_FALLBACK"
+
+// contextLogger is a Logger installed in a context, with the context's
+// fields at the time, which the logger already includes.
+type contextLogger struct {
+	logger Logger
+	fields *fieldsNode
+}
+
+// fieldsNode is a list of fields added to a context by
+// FieldsContext, linked to the fields previously in the context.
+type fieldsNode struct {
+	parent  *fieldsNode
+	keyvals []interface{}
+}
 
 func WithLogger(logger Logger) func(http.Handler) http.Handler {
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
 			traceID, ok := trace.RequestTrace(ctx)
-			l := logger // don't overwrite logger
+			l := logger.With(Fields(ctx)...) // don't overwrite logger
 			if ok {
-				l = logger.With("req_id", traceID)
+				l = l.With("req_id", traceID)
 			}
-			newCtx := context.WithValue(ctx, loggerKey, l)
-			h.ServeHTTP(w, r.WithContext(newCtx))
+			h.ServeHTTP(w, r.WithContext(Context(ctx, l)))
 		})
 	}
 }
 
-// Context installs a given Logger in the returned context
+// Context installs a given Logger in the returned context. The logger
+// is assumed to include any fields already in the context (eg.
+// because it was returned by FromContext), fields added to the
+// context later are added by FromContext.
 func Context(ctx context.Context, l Logger) context.Context {
-	return context.WithValue(ctx, loggerKey, l)
+	fields, _ := ctx.Value(fieldsKey).(*fieldsNode)
+	return context.WithValue(ctx, loggerKey, contextLogger{logger: l, fields: fields})
 }
 
-// FromContext extracts a Logger from a (possibly nil) context.
+// FromContext extracts a Logger from a (possibly nil) context. The
+// logger includes any fields added to the context by FieldsContext
+// since the logger was installed.
 func FromContext(c context.Context) (Logger, bool) {
-	if c != nil {
-		logger, ok := c.Value(loggerKey).(Logger)
-		return logger, ok
+	if c == nil {
+		return Logger{}, false
 	}
-	return Logger{}, false
+
+	cl, ok := c.Value(loggerKey).(contextLogger)
+	if !ok {
+		return Logger{}, false
+	}
+
+	if fields := fieldsSince(c, cl.fields); len(fields) > 0 {
+		return cl.logger.With(fields...), true
+	}
+	return cl.logger, true
 }
 
 // ForceContext extracts a Logger from a (possibly nil) context, or
-// returns a log.Default().
+// returns a log.Default() with the context's fields.
+//
+// If `APPKIT_LOG_WARN_FALLBACK` is set, falling back to log.Default()
+// in a request context (one with a request trace or fields) logs a
+// warning with the stacktrace, to find code that is missing the
+// request's logger.
 func ForceContext(c context.Context) Logger {
 	logger, ok := FromContext(c)
-	if !ok {
-		logger = Default()
+	if ok {
+		return logger
+	}
+
+	logger = Default()
+	if c == nil {
+		return logger
+	}
+
+	fields := Fields(c)
+	_, traced := trace.RequestTrace(c)
+	if (traced || len(fields) > 0) && warnOnFallback() {
+		logger.With(fields...).Warn().Log(
+			"context", "appkit/log.ForceContext",
+			"msg", "no logger in request context, falling back to log.Default()",
+			"stacktrace", string(debug.Stack()),
+		)
+	}
+
+	return logger.With(fields...)
+}
+
+func warnOnFallback() bool {
+	warn := strings.ToLower(os.Getenv(warnFallbackLogEnvName))
+	return len(warn) > 0 && warn != "false" && warn != "0"
+}
+
+// FieldsContext returns a context with keyvals added to its fields.
+// Loggers returned by FromContext (or ForceContext) include all the
+// fields of the context, so middleware can attach fields like a user
+// ID or route to every log of a request.
+func FieldsContext(ctx context.Context, keyvals ...interface{}) context.Context {
+	if len(keyvals) == 0 {
+		return ctx
+	}
+	if len(keyvals)%2 == 1 {
+		keyvals = append(keyvals, "<value-missing>")
+	}
+
+	parent, _ := ctx.Value(fieldsKey).(*fieldsNode)
+	return context.WithValue(ctx, fieldsKey, &fieldsNode{parent: parent, keyvals: keyvals})
+}
+
+// Fields returns all fields added to ctx by FieldsContext, in the
+// order they were added.
+func Fields(ctx context.Context) []interface{} {
+	return fieldsSince(ctx, nil)
+}
+
+// fieldsSince returns the fields added to ctx after since.
+func fieldsSince(ctx context.Context, since *fieldsNode) []interface{} {
+	var nodes []*fieldsNode
+	for n, _ := ctx.Value(fieldsKey).(*fieldsNode); n != nil && n != since; n = n.parent {
+		nodes = append(nodes, n)
+	}
+
+	var keyvals []interface{}
+	for i := len(nodes) - 1; i >= 0; i-- {
+		keyvals = append(keyvals, nodes[i].keyvals...)
+	}
+	return keyvals
+}
+
+// WithFields is HTTP middleware that adds the fields returned by f
+// for every request to the request's context, eg. to log the
+// authenticated user:
+//
+//	log.WithFields(func(r *http.Request) []interface{} {
+//	    return []interface{}{"user_id", userID(r)}
+//	})
+func WithFields(f func(r *http.Request) []interface{}) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r.WithContext(FieldsContext(r.Context(), f(r)...)))
+		})
 	}
-	return logger
 }
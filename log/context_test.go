@@ -0,0 +1,57 @@
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	klog "github.com/go-kit/kit/log"
+	"github.com/theplant/appkit/log"
+)
+
+func TestContextFields(t *testing.T) {
+	output := bytes.NewBuffer(nil)
+	base := log.Logger{Logger: klog.NewLogfmtLogger(output)}
+
+	handler := log.WithFields(func(r *http.Request) []interface{} {
+		return []interface{}{"tenant", "acme"}
+	})(log.WithLogger(base)(log.WithFields(func(r *http.Request) []interface{} {
+		return []interface{}{"route", r.URL.Path}
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := log.FieldsContext(r.Context(), "user_id", 1)
+
+		l, ok := log.FromContext(ctx)
+		if !ok {
+			t.Fatal("no logger in context")
+		}
+		l.Log("msg", "first")
+
+		// Re-installing a logger from the context doesn't duplicate fields
+		ctx = log.Context(ctx, l.With("step", 2))
+		log.ForceContext(log.FieldsContext(ctx, "extra", true)).Log("msg", "second")
+	}))))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/orders", nil))
+
+	expected := `tenant=acme route=/orders user_id=1 msg=first
+tenant=acme route=/orders user_id=1 step=2 extra=true msg=second
+`
+	if got := output.String(); got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestFieldsWithoutLogger(t *testing.T) {
+	ctx := log.FieldsContext(context.Background(), "a", 1)
+	ctx = log.FieldsContext(ctx, "b")
+
+	if got, want := len(log.Fields(ctx)), 4; got != want {
+		t.Errorf("got %d fields, want %d", got, want)
+	}
+
+	if _, ok := log.FromContext(ctx); ok {
+		t.Error("expected no logger in context")
+	}
+}
@@ -0,0 +1,14 @@
+package log
+
+// NewDefault exposes newDefault to tests, to check the output of
+// Default.
+var NewDefault = newDefault
+
+// UseColorsFor exposes useColors to tests, to check the color
+// detection without a terminal.
+var UseColorsFor = useColors
+
+// PrettyFormatColors is PrettyFormat, with or without colors.
+func PrettyFormatColors(colors bool, values ...interface{}) string {
+	return humanFormat{colors: colors, longValue: 50}.format(values...)
+}
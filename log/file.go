@@ -0,0 +1,352 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fileLogEnvName               = "APPKIT_LOG_FILE"
+	fileMaxSizeLogEnvName        = "APPKIT_LOG_FILE_MAX_SIZE"
+	fileRotateIntervalLogEnvName = "APPKIT_LOG_FILE_ROTATE_INTERVAL"
+	fileMaxBackupsLogEnvName     = "APPKIT_LOG_FILE_MAX_BACKUPS"
+	fileCompressLogEnvName       = "APPKIT_LOG_FILE_COMPRESS"
+)
+
+// rotatedTimeFormat is appended to the file path of rotated files,
+// and sorts in the order files were rotated. Files rotated in the
+// same millisecond get a `_001`, `_002`, ... counter suffix.
+const rotatedTimeFormat = "20060102T150405.000"
+
+// FileConfig configures a FileWriter.
+type FileConfig struct {
+	// Path of the log file, created if it doesn't exist.
+	Path string
+
+	// MaxSize in bytes of the log file, after which it is rotated. 0
+	// disables size-based rotation.
+	MaxSize int64
+
+	// RotateInterval is how long a log file is written to before it
+	// is rotated. 0 disables time-based rotation.
+	RotateInterval time.Duration
+
+	// MaxBackups is the number of rotated files to keep, older files
+	// are deleted. 0 keeps all rotated files.
+	MaxBackups int
+
+	// Compress rotated files with gzip.
+	Compress bool
+}
+
+// FileWriter is an io.Writer that writes to a file, rotating it by
+// size and/or time. Rotated files are renamed to
+// `<path>.<timestamp>`, with a `.gz` suffix if compressed.
+type FileWriter struct {
+	config FileConfig
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+
+	// closed by Close, file is also nil after failing to reopen it,
+	// and is opened again by the next write
+	closed bool
+
+	// background compression and cleanup of rotated files
+	rotations sync.WaitGroup
+}
+
+// NewFileWriter opens the file at config.Path for appending, and
+// returns a FileWriter writing to it.
+func NewFileWriter(config FileConfig) (*FileWriter, error) {
+	w := &FileWriter{config: config}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *FileWriter) open() error {
+	f, err := os.OpenFile(w.config.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening log file: %v", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("error opening log file: %v", err)
+	}
+
+	w.file, w.size, w.openedAt = f, info.Size(), time.Now()
+	return nil
+}
+
+// Write is part of io.Writer, it rotates the file before writing p if
+// p would make the file larger than MaxSize, or if the file is older
+// than RotateInterval.
+func (w *FileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.ensureOpen(); err != nil {
+		return 0, err
+	}
+
+	if w.shouldRotate(len(p)) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *FileWriter) shouldRotate(n int) bool {
+	if w.config.MaxSize > 0 && w.size > 0 && w.size+int64(n) > w.config.MaxSize {
+		return true
+	}
+	return w.config.RotateInterval > 0 && time.Since(w.openedAt) >= w.config.RotateInterval
+}
+
+// Rotate closes the current file, renames it, and opens a new file.
+func (w *FileWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.ensureOpen(); err != nil {
+		return err
+	}
+	return w.rotate()
+}
+
+// ensureOpen opens the file again if reopening it failed before.
+func (w *FileWriter) ensureOpen() error {
+	if w.closed {
+		return os.ErrClosed
+	}
+	if w.file == nil {
+		return w.open()
+	}
+	return nil
+}
+
+func (w *FileWriter) rotate() error {
+	err := w.file.Close()
+	w.file = nil
+	if err != nil {
+		return fmt.Errorf("error closing log file: %v", err)
+	}
+
+	rotated := w.rotatedPath(time.Now())
+	if err := os.Rename(w.config.Path, rotated); err != nil {
+		// keep writing to the current file
+		w.open()
+		return fmt.Errorf("error rotating log file: %v", err)
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	w.rotations.Add(1)
+	go func() {
+		defer w.rotations.Done()
+		if w.config.Compress {
+			compressFile(rotated)
+		}
+		w.removeOldBackups()
+	}()
+
+	return nil
+}
+
+// Reopen closes and reopens the file at the configured path, for use
+// after the file was moved by an external tool like logrotate.
+func (w *FileWriter) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return os.ErrClosed
+	}
+
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+	return w.open()
+}
+
+// rotatedPath returns the path to rename the file to when rotating it
+// at t, that doesn't overwrite a file rotated in the same millisecond.
+func (w *FileWriter) rotatedPath(t time.Time) string {
+	base := w.config.Path + "." + t.Format(rotatedTimeFormat)
+	path := base
+	for i := 1; fileExists(path) || fileExists(path+".gz"); i++ {
+		path = fmt.Sprintf("%s_%03d", base, i)
+	}
+	return path
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// Close closes the file, and waits for rotated files to be
+// compressed.
+func (w *FileWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.rotations.Wait()
+
+	w.closed = true
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// compressFile gzips path into path.gz, and removes path. Errors are
+// ignored, leaving the uncompressed file behind.
+func compressFile(path string) {
+	src, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return
+	}
+
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if cerr := gz.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		os.Remove(path + ".gz")
+		return
+	}
+	os.Remove(path)
+}
+
+// removeOldBackups deletes the oldest rotated files, keeping
+// MaxBackups files.
+func (w *FileWriter) removeOldBackups() {
+	if w.config.MaxBackups <= 0 {
+		return
+	}
+
+	backups, err := filepath.Glob(w.config.Path + ".*")
+	if err != nil {
+		return
+	}
+
+	// An uncompressed file and its compressed version count as one
+	// backup
+	names := map[string][]string{}
+	for _, backup := range backups {
+		name := strings.TrimSuffix(backup, ".gz")
+		suffix := strings.TrimPrefix(name, w.config.Path+".")
+		if i := strings.LastIndex(suffix, "_"); i >= 0 {
+			suffix = suffix[:i]
+		}
+		if _, err := time.Parse(rotatedTimeFormat, suffix); err != nil {
+			continue
+		}
+		names[name] = append(names[name], backup)
+	}
+
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for len(sorted) > w.config.MaxBackups {
+		for _, backup := range names[sorted[0]] {
+			os.Remove(backup)
+		}
+		sorted = sorted[1:]
+	}
+}
+
+// fileConfigFromEnv returns the FileConfig set by `APPKIT_LOG_FILE*`
+// environment variables, and whether a file is configured.
+func fileConfigFromEnv() (config FileConfig, ok bool, err error) {
+	config.Path = os.Getenv(fileLogEnvName)
+	if config.Path == "" {
+		return config, false, nil
+	}
+
+	if v := os.Getenv(fileMaxSizeLogEnvName); v != "" {
+		if config.MaxSize, err = parseSize(v); err != nil {
+			return config, true, fmt.Errorf("invalid %s %q: %v", fileMaxSizeLogEnvName, v, err)
+		}
+	}
+
+	if v := os.Getenv(fileRotateIntervalLogEnvName); v != "" {
+		if config.RotateInterval, err = time.ParseDuration(v); err != nil {
+			return config, true, fmt.Errorf("invalid %s %q: %v", fileRotateIntervalLogEnvName, v, err)
+		}
+	}
+
+	if v := os.Getenv(fileMaxBackupsLogEnvName); v != "" {
+		if config.MaxBackups, err = strconv.Atoi(v); err != nil {
+			return config, true, fmt.Errorf("invalid %s %q: %v", fileMaxBackupsLogEnvName, v, err)
+		}
+	}
+
+	compress := strings.ToLower(os.Getenv(fileCompressLogEnvName))
+	config.Compress = len(compress) > 0 && compress != "false" && compress != "0"
+
+	return config, true, nil
+}
+
+// parseSize parses sizes in bytes, with optional `k`, `m` or `g`
+// (1024-based) suffixes, eg. "100M" or "100mb".
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), "b")
+
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(s, "k"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(s, "m"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(s, "g"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n * multiplier, nil
+}
@@ -0,0 +1,161 @@
+package log_test
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/theplant/appkit/log"
+)
+
+func TestFileWriterRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "appkit-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.log")
+	w, err := log.NewFileWriter(log.FileConfig{
+		Path:       path,
+		MaxSize:    10,
+		MaxBackups: 2,
+		Compress:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"line 1\n", "line 2\n", "line 3\n", "line 4\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	current, _ := ioutil.ReadFile(path)
+	if got, want := string(current), "line 4\n"; got != want {
+		t.Errorf("got %q in current file, want %q", got, want)
+	}
+
+	backups, _ := filepath.Glob(path + ".*.gz")
+	if len(backups) != 2 {
+		t.Fatalf("got backups %v, want 2", backups)
+	}
+
+	sort.Strings(backups)
+	f, err := os.Open(backups[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest, _ := ioutil.ReadAll(gz)
+	if got, want := string(latest), "line 3\n"; got != want {
+		t.Errorf("got %q in latest backup, want %q", got, want)
+	}
+}
+
+func TestFileWriterReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "appkit-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.log")
+	w, err := log.NewFileWriter(log.FileConfig{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	w.Write([]byte("before\n"))
+
+	// eg. logrotate
+	os.Rename(path, path+".1")
+
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("after\n"))
+
+	current, _ := ioutil.ReadFile(path)
+	if got, want := string(current), "after\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFileWriterRotateInSameMillisecond(t *testing.T) {
+	dir, err := ioutil.TempDir("", "appkit-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.log")
+	w, err := log.NewFileWriter(log.FileConfig{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		w.Write([]byte("line\n"))
+		if err := w.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) != 5 {
+		t.Errorf("got backups %v, want 5", backups)
+	}
+}
+
+func TestFileWriterReopenFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "appkit-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.log")
+	w, err := log.NewFileWriter(log.FileConfig{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// a directory in place of the log file fails to open
+	os.Remove(path)
+	os.Mkdir(path, 0755)
+
+	if err := w.Reopen(); err == nil {
+		t.Fatal("expected Reopen to fail")
+	}
+	if _, err := w.Write([]byte("lost\n")); err == nil {
+		t.Fatal("expected Write to fail")
+	}
+
+	os.Remove(path)
+
+	if _, err := w.Write([]byte("after\n")); err != nil {
+		t.Fatalf("Write didn't reopen the file: %v", err)
+	}
+
+	current, _ := ioutil.ReadFile(path)
+	if got, want := string(current), "after\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -2,39 +2,171 @@ package log
 
 import (
 	"fmt"
+	"io"
 	"os"
-
-	slog "log"
-
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/go-kit/kit/log"
 )
 
+const noColorEnvName = "NO_COLOR"
+
+// ColorMode selects whether the human format uses ANSI colors.
+type ColorMode int
+
+const (
+	// ColorAuto uses colors if the output is a terminal, and the
+	// `NO_COLOR` environment variable is not set.
+	ColorAuto ColorMode = iota
+	ColorAlways
+	ColorNever
+)
+
+// HumanOptions configures a human friendly logger created with
+// NewHuman. Zero values are replaced by the defaults noted for each
+// field.
+type HumanOptions struct {
+	// Output is where logs are written. Defaults to the writer of
+	// `Default` (stdout, or the file set by `APPKIT_LOG_FILE`).
+	Output io.Writer
+
+	// Color selects whether to use ANSI colors. Defaults to ColorAuto.
+	Color ColorMode
+
+	// FieldOrder lists keys that are printed first, in this order.
+	// Other fields are printed in the order they were logged, or
+	// sorted by key if SortFields is set.
+	FieldOrder []string
+	SortFields bool
+
+	// LongValueLength is the length above which a value is printed on
+	// its own line. Defaults to 50.
+	LongValueLength int
+
+	// TimeFormat is the format of the `ts` field. Defaults to
+	// "15:04:05.99".
+	TimeFormat string
+
+	// Caller adds the file and line of the log call.
+	Caller bool
+}
+
 /*
-Human is for create a Logger that print human friendly log
+Human is for create a Logger that print human friendly log, with
+default HumanOptions
 */
 func Human() Logger {
-	l := log.NewSyncWriter(os.Stdout)
+	return NewHuman(HumanOptions{})
+}
+
+// NewHuman creates a Logger that prints human friendly logs,
+// configured by opts.
+//
+// Unlike in previous versions, the go standard log is not redirected
+// to the logger, use SetStdLogOutput to do that.
+func NewHuman(opts HumanOptions) Logger {
+	w := opts.Output
+	var terminal bool
+	if w == nil {
+		w = defaultWriter()
+		terminal = defaultWriterIsTerminal()
+	} else {
+		if f, ok := w.(*os.File); ok {
+			terminal = isTerminal(f)
+		}
+		w = log.NewSyncWriter(w)
+	}
+
+	format := humanFormat{
+		colors:     useColors(opts.Color, terminal),
+		longValue:  opts.LongValueLength,
+		fieldOrder: opts.FieldOrder,
+		sortFields: opts.SortFields,
+	}
+	if format.longValue <= 0 {
+		format.longValue = 50
+	}
+
+	timeFormat := opts.TimeFormat
+	if timeFormat == "" {
+		timeFormat = "15:04:05.99"
+	}
+
 	lg := Logger{
-		log.LoggerFunc(func(values ...interface{}) (err error) {
-			fmt.Fprint(l, PrettyFormat(values...))
+		NewLevelFilter(NewRedactingLogger(log.LoggerFunc(func(values ...interface{}) (err error) {
+			fmt.Fprint(w, format.format(values...))
 			return
-		}),
+		}), DefaultRedactor), DefaultLevels),
 	}
-	var timer log.Valuer = func() interface{} { return time.Now().Format("15:04:05.99") }
+	var timer log.Valuer = func() interface{} { return time.Now().Format(timeFormat) }
 	lg = lg.With("ts", timer)
+	if opts.Caller {
+		lg = lg.With("caller", log.Caller(4))
+	}
 
-	slog.SetOutput(log.NewStdlibAdapter(lg))
+	warnDefaultConfig(lg)
 
 	return lg
 }
 
+func useColors(mode ColorMode, terminal bool) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	default:
+		return terminal && os.Getenv(noColorEnvName) == ""
+	}
+}
+
+// UseColors reports whether colors should be used when writing to f:
+// f is a terminal, and the `NO_COLOR` environment variable isn't set.
+func UseColors(f *os.File) bool {
+	return useColors(ColorAuto, isTerminal(f))
+}
+
+// isTerminal reports whether f is a terminal (a character device).
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
 /*
-PrettyFormat accepts log values and returns pretty output string
+PrettyFormat accepts log values and returns pretty output string, with
+colors if stdout is a terminal and `NO_COLOR` isn't set (see
+UseColors)
 */
 func PrettyFormat(values ...interface{}) (r string) {
-	var ts, msg, level, stacktrace, sql, sqlValues interface{}
+	return humanFormat{colors: stdoutColors(), longValue: 50}.format(values...)
+}
+
+var stdoutColors = sync.OnceValue(func() bool {
+	return UseColors(os.Stdout)
+})
+
+// humanFormat is the human friendly format of NewHuman and
+// PrettyFormat.
+type humanFormat struct {
+	colors     bool
+	longValue  int
+	fieldOrder []string
+	sortFields bool
+}
+
+// color wraps s in the ANSI color code, and reset code if set.
+func (f humanFormat) color(code string, s interface{}, reset string) string {
+	if !f.colors {
+		return fmt.Sprint(s)
+	}
+	return fmt.Sprintf("\033[%sm%v%s", code, s, reset)
+}
+
+func (f humanFormat) format(values ...interface{}) (r string) {
+	var ts, caller, msg, level, stacktrace, sql, sqlValues interface{}
+	var fields []*kv
 	var shorts []interface{}
 	var longs []interface{}
 	var isSQL bool
@@ -46,6 +178,10 @@ func PrettyFormat(values ...interface{}) (r string) {
 			ts = val
 			continue
 		}
+		if key == "caller" {
+			caller = val
+			continue
+		}
 		if key == "msg" {
 			msg = val
 			continue
@@ -72,23 +208,33 @@ func PrettyFormat(values ...interface{}) (r string) {
 			continue
 		}
 
-		if len(fmt.Sprintf("%+v", val)) > 50 {
-			longs = append(longs, fmt.Sprintf("\033[34m%+v\033[39m=%+v", key, val))
+		fields = append(fields, &kv{key: fmt.Sprint(key), val: val})
+	}
+
+	for _, field := range f.order(fields) {
+		if len(fmt.Sprintf("%+v", field.val)) > f.longValue {
+			longs = append(longs, fmt.Sprintf("%s=%+v", f.color("34", field.key, "\033[39m"), field.val))
 			continue
 		}
 
-		shorts = append(shorts, fmt.Sprintf("\033[34m%+v\033[39m=%+v", key, val))
+		shorts = append(shorts, fmt.Sprintf("%s=%+v", f.color("34", field.key, "\033[39m"), field.val))
 	}
 
 	var pvals = []interface{}{}
 	if ts != nil {
-		pvals = append(pvals, fmt.Sprintf("\033[36m%s\033[0m", ts))
+		pvals = append(pvals, f.color("36", ts, "\033[0m"))
+	}
+
+	if caller != nil {
+		pvals = append(pvals, f.color("90", caller, "\033[0m"))
 	}
 
 	if msg != nil {
 		color := "39"
 		level = fmt.Sprintf("%+v", level)
 		switch level {
+		case "fatal":
+			color = "35;1"
 		case "crit":
 			color = "35"
 		case "error":
@@ -97,10 +243,10 @@ func PrettyFormat(values ...interface{}) (r string) {
 			color = "33"
 		case "info":
 			color = "32"
-		case "debug":
+		case "debug", "trace":
 			color = "90"
 		}
-		pvals = append(pvals, fmt.Sprintf("\033[%sm%s", color, msg))
+		pvals = append(pvals, f.color(color, msg, ""))
 	}
 
 	pvals = append(pvals, shorts...)
@@ -114,7 +260,7 @@ func PrettyFormat(values ...interface{}) (r string) {
 	if sql != nil {
 		pvals = append(pvals, fmt.Sprintf("\n            %s", sql), "\n")
 		if sqlValues != nil {
-			pvals = append(pvals, fmt.Sprintf("           \033[34m%s\033[0m=%s", "values", sqlValues), "\n")
+			pvals = append(pvals, fmt.Sprintf("           %s=%s", f.color("34", "values", "\033[0m"), sqlValues), "\n")
 		}
 	}
 
@@ -124,3 +270,45 @@ func PrettyFormat(values ...interface{}) (r string) {
 
 	return fmt.Sprintln(pvals...)
 }
+
+type kv struct {
+	key string
+	val interface{}
+}
+
+// order returns fields with the keys of fieldOrder first, followed by
+// other fields, sorted by key if sortFields is set.
+func (f humanFormat) order(fields []*kv) []*kv {
+	if len(f.fieldOrder) == 0 && !f.sortFields {
+		return fields
+	}
+
+	ordered := make([]*kv, 0, len(fields))
+	rest := make([]*kv, 0, len(fields))
+	for _, key := range f.fieldOrder {
+		for _, field := range fields {
+			if field.key == key {
+				ordered = append(ordered, field)
+			}
+		}
+	}
+	for _, field := range fields {
+		if !contains(f.fieldOrder, field.key) {
+			rest = append(rest, field)
+		}
+	}
+
+	if f.sortFields {
+		sort.SliceStable(rest, func(i, j int) bool { return rest[i].key < rest[j].key })
+	}
+	return append(ordered, rest...)
+}
+
+func contains(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package log_test
+
+import (
+	"bytes"
+	stdl "log"
+	"strings"
+	"testing"
+
+	"github.com/theplant/appkit/log"
+)
+
+func TestNewHuman(t *testing.T) {
+	output := bytes.NewBuffer(nil)
+	l := log.NewHuman(log.HumanOptions{
+		Output:          output,
+		Color:           log.ColorNever,
+		FieldOrder:      []string{"user_id"},
+		SortFields:      true,
+		LongValueLength: 10,
+		TimeFormat:      "ts",
+	})
+
+	l.Info().Log("msg", "hello", "z", 1, "a", 2, "user_id", 3, "long", "a value longer than 10")
+
+	expected := "ts hello user_id=3 a=2 z=1 \n            long=a value longer than 10 \n\n"
+	if got := output.String(); got != expected {
+		t.Errorf("got:\n%q\nwant:\n%q", got, expected)
+	}
+}
+
+func TestNewHumanColors(t *testing.T) {
+	output := bytes.NewBuffer(nil)
+	l := log.NewHuman(log.HumanOptions{Output: output, Color: log.ColorAlways, TimeFormat: "ts"})
+	l.Warn().Log("msg", "hello", "a", 1)
+
+	expected := "\033[36mts\033[0m \033[33mhello \033[34ma\033[39m=1\n"
+	if got := output.String(); got != expected {
+		t.Errorf("got:\n%q\nwant:\n%q", got, expected)
+	}
+
+	// Auto doesn't use colors when not writing to a terminal
+	output.Reset()
+	l = log.NewHuman(log.HumanOptions{Output: output, TimeFormat: "ts"})
+	l.Warn().Log("msg", "hello")
+
+	if got := output.String(); strings.Contains(got, "\033[") {
+		t.Errorf("expected no colors, got %q", got)
+	}
+}
+
+func TestHumanDoesNotRedirectStdLog(t *testing.T) {
+	orig := stdl.Writer()
+	defer stdl.SetOutput(orig)
+
+	stdOutput := bytes.NewBuffer(nil)
+	stdl.SetOutput(stdOutput)
+
+	log.NewHuman(log.HumanOptions{Output: bytes.NewBuffer(nil)})
+	stdl.Print("hello")
+
+	if !strings.Contains(stdOutput.String(), "hello") {
+		t.Errorf("expected go standard log to be unchanged, got %q", stdOutput.String())
+	}
+}
+
+func TestPrettyFormatColors(t *testing.T) {
+	if got := log.PrettyFormatColors(false, "level", "warn", "msg", "hello"); strings.Contains(got, "\033[") {
+		t.Errorf("expected no colors, got %q", got)
+	}
+	if got := log.PrettyFormatColors(true, "level", "warn", "msg", "hello"); !strings.Contains(got, "\033[") {
+		t.Errorf("expected colors, got %q", got)
+	}
+}
+
+func TestUseColors(t *testing.T) {
+	cases := []struct {
+		mode     log.ColorMode
+		terminal bool
+		noColor  string
+		expected bool
+	}{
+		{log.ColorAuto, true, "", true},
+		{log.ColorAuto, false, "", false},
+		{log.ColorAuto, true, "1", false},
+		{log.ColorAlways, false, "1", true},
+		{log.ColorNever, true, "", false},
+	}
+
+	for _, c := range cases {
+		t.Setenv("NO_COLOR", c.noColor)
+		if got := log.UseColorsFor(c.mode, c.terminal); got != c.expected {
+			t.Errorf("useColors(%v, terminal %v) with NO_COLOR=%q = %v, want %v", c.mode, c.terminal, c.noColor, got, c.expected)
+		}
+	}
+}
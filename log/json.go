@@ -0,0 +1,183 @@
+package log
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+type jsonLogger struct {
+	io.Writer
+}
+
+// NewJSONLogger returns a logger that encodes keyvals to the writer
+// as a single JSON object per line. Unlike go-kit's JSON logger, key
+// order is preserved, and errors, `time.Time`s and `fmt.Stringer`s
+// are encoded as strings, even when nested in maps or slices.
+func NewJSONLogger(w io.Writer) log.Logger {
+	return jsonLogger{w}
+}
+
+func (l jsonLogger) Log(keyvals ...interface{}) error {
+	var keys []string
+	values := map[string]interface{}{}
+
+	for i := 0; i < len(keyvals); i += 2 {
+		key := jsonKey(keyvals[i])
+		var val interface{} = log.ErrMissingValue
+		if i+1 < len(keyvals) {
+			val = keyvals[i+1]
+		}
+
+		// Later values overwrite earlier ones, but keep the position of
+		// the first occurrence
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+		values[key] = jsonValue(val, 0)
+	}
+
+	buf := bytes.NewBufferString("{")
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSON(buf, key)
+		buf.WriteByte(':')
+		writeJSON(buf, values[key])
+	}
+	buf.WriteString("}\n")
+
+	_, err := l.Write(buf.Bytes())
+	return err
+}
+
+// writeJSON encodes v into buf, falling back to encoding v as a
+// string if v can't be encoded as JSON (eg. channels or functions).
+func writeJSON(buf *bytes.Buffer, v interface{}) {
+	b, err := marshalJSON(v)
+	if err != nil {
+		b, _ = marshalJSON(fmt.Sprintf("%+v", v))
+	}
+	buf.Write(b)
+}
+
+func marshalJSON(v interface{}) ([]byte, error) {
+	b := bytes.NewBuffer(nil)
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(b.Bytes(), "\n"), nil
+}
+
+func jsonKey(k interface{}) string {
+	switch x := k.(type) {
+	case string:
+		return x
+	case fmt.Stringer:
+		return safeString(x)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// maxJSONDepth is the depth of nested maps, slices and pointers that
+// jsonValue converts, so that logging a value that refers to itself
+// doesn't recurse forever.
+const maxJSONDepth = 10
+
+// jsonValue converts v to a value encoding/json can encode, at depth
+// of the logged value. Values nested deeper than maxJSONDepth are
+// logged as their type.
+func jsonValue(v interface{}, depth int) interface{} {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	case json.Marshaler, encoding.TextMarshaler:
+		// Let encoding/json handle these
+		return v
+	case error:
+		return safeError(x)
+	case fmt.Stringer:
+		return safeString(x)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Ptr, reflect.Interface:
+		if depth >= maxJSONDepth {
+			return fmt.Sprintf("<%T nested too deep>", v)
+		}
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil
+		}
+		m := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[jsonKey(iter.Key().Interface())] = jsonValue(iter.Value().Interface(), depth+1)
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			// Let encoding/json encode []byte as base64
+			return v
+		}
+		s := make([]interface{}, rv.Len())
+		for i := range s {
+			s[i] = jsonValue(rv.Index(i).Interface(), depth+1)
+		}
+		return s
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		return jsonValue(rv.Elem().Interface(), depth+1)
+	}
+
+	return v
+}
+
+func safeString(str fmt.Stringer) (s string) {
+	defer func() {
+		if panicVal := recover(); panicVal != nil {
+			if v := reflect.ValueOf(str); v.Kind() == reflect.Ptr && v.IsNil() {
+				s = "NULL"
+			} else {
+				panic(panicVal)
+			}
+		}
+	}()
+	s = str.String()
+	return
+}
+
+func safeError(err error) (s interface{}) {
+	defer func() {
+		if panicVal := recover(); panicVal != nil {
+			if v := reflect.ValueOf(err); v.Kind() == reflect.Ptr && v.IsNil() {
+				s = nil
+			} else {
+				panic(panicVal)
+			}
+		}
+	}()
+	s = err.Error()
+	return
+}
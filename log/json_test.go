@@ -0,0 +1,107 @@
+package log_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theplant/appkit/log"
+)
+
+type stringer string
+
+func (s stringer) String() string { return "stringer:" + string(s) }
+
+func TestJSONLogger(t *testing.T) {
+	output := bytes.NewBuffer(nil)
+	l := log.NewJSONLogger(output)
+
+	at := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	err := l.Log(
+		"msg", "hello <world>",
+		"err", errors.New("oops"),
+		"at", at,
+		"s", stringer("x"),
+		"nested", map[interface{}]interface{}{
+			1:      errors.New("inner"),
+			"list": []interface{}{at, stringer("y")},
+		},
+		"msg", "overwritten",
+		"odd",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"msg":"overwritten","err":"oops","at":"2018-01-02T03:04:05Z","s":"stringer:x","nested":{"1":"inner","list":["2018-01-02T03:04:05Z","stringer:y"]},"odd":"(MISSING)"}` + "\n"
+	if got := output.String(); got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestJSONLoggerCycles(t *testing.T) {
+	output := bytes.NewBuffer(nil)
+	l := log.NewJSONLogger(output)
+
+	m := map[string]interface{}{"name": "m"}
+	m["self"] = m
+	s := []interface{}{"s"}
+	s = append(s, s)
+	s[1] = s
+
+	if err := l.Log("map", m, "slice", s); err != nil {
+		t.Fatal(err)
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(output.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", output.String(), err)
+	}
+	if !strings.Contains(output.String(), "nested too deep") {
+		t.Errorf("expected the cycles to be cut, got %s", output.String())
+	}
+}
+
+func TestDefaultFormat(t *testing.T) {
+	t.Setenv("APPKIT_LOG_HUMAN", "")
+
+	cases := []struct {
+		format string
+		check  func(line string) bool
+	}{
+		{"", isLogfmt},
+		{"logfmt", isLogfmt},
+		{"json", isJSON},
+		{"human", func(line string) bool {
+			return !isLogfmt(line) && !isJSON(line) && strings.Contains(line, "hello")
+		}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.format, func(t *testing.T) {
+			t.Setenv("APPKIT_LOG_FORMAT", c.format)
+
+			output := bytes.NewBuffer(nil)
+			if err := log.NewDefault(output).Info().Log("msg", "hello"); err != nil {
+				t.Fatal(err)
+			}
+
+			if line := output.String(); !c.check(line) {
+				t.Errorf("unexpected output for format %q: %q", c.format, line)
+			}
+		})
+	}
+}
+
+func isLogfmt(line string) bool {
+	return strings.HasPrefix(line, "level=info ") && strings.Contains(line, " msg=hello")
+}
+
+func isJSON(line string) bool {
+	var entry map[string]interface{}
+	return json.Unmarshal([]byte(line), &entry) == nil && entry["msg"] == "hello" && entry["level"] == "info"
+}
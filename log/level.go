@@ -0,0 +1,293 @@
+package log
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const levelLogEnvName = "APPKIT_LOG_LEVEL"
+
+// Level is a log level that can be used as a minimum level to filter
+// logs.
+type Level int
+
+const (
+	LevelTrace Level = iota
+	LevelDebug
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelCrit
+	LevelFatal
+)
+
+var levelNames = map[Level]string{
+	LevelTrace: "trace",
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+	LevelCrit:  "crit",
+	LevelFatal: "fatal",
+}
+
+// levelValue is the value logged in the `level` field for levels that
+// go-kit's level package doesn't provide.
+type levelValue Level
+
+func (v levelValue) String() string { return Level(v).String() }
+
+var (
+	traceValue = levelValue(LevelTrace)
+	critValue  = levelValue(LevelCrit)
+	fatalValue = levelValue(LevelFatal)
+)
+
+// LevelOf returns the level of a log from its keyvals, and whether
+// the log has a known level.
+func LevelOf(keyvals ...interface{}) (Level, bool) {
+	for i := 1; i < len(keyvals); i += 2 {
+		if keyvals[i-1] == level.Key() {
+			if l, err := ParseLevel(fmt.Sprint(keyvals[i])); err == nil {
+				return l, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// ParseLevel parses a level name as logged in the `level` field,
+// eg. "info".
+func ParseLevel(s string) (Level, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for l, name := range levelNames {
+		if name == s {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+// Levels holds a minimum log level, with optional overrides for logs
+// with a specific `context` field. It is safe for concurrent use, so
+// levels can be changed while the application is running.
+//
+// Levels are configured with a spec string: a comma-separated list
+// of a default level and `context=level` overrides, eg.
+// "info,gorm=debug,http=warn".
+type Levels struct {
+	mu       sync.RWMutex
+	min      Level
+	contexts map[string]Level
+
+	// previous spec, restored by ToggleDebug
+	toggled string
+}
+
+// NewLevels returns Levels configured by spec.
+func NewLevels(spec string) (*Levels, error) {
+	l := &Levels{}
+	if err := l.Set(spec); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Set replaces the current configuration with the one in spec. Empty
+// spec allows all levels.
+func (l *Levels) Set(spec string) error {
+	min, contexts, err := parseLevels(spec)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.min, l.contexts, l.toggled = min, contexts, ""
+	return nil
+}
+
+func parseLevels(spec string) (min Level, contexts map[string]Level, err error) {
+	contexts = map[string]Level{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		if i := strings.LastIndex(part, "="); i >= 0 {
+			lvl, err := ParseLevel(part[i+1:])
+			if err != nil {
+				return 0, nil, fmt.Errorf("invalid log level for context %q: %v", part[:i], err)
+			}
+			contexts[strings.TrimSpace(part[:i])] = lvl
+			continue
+		}
+
+		if min, err = ParseLevel(part); err != nil {
+			return 0, nil, err
+		}
+	}
+	return min, contexts, nil
+}
+
+// String returns the spec of the current configuration.
+func (l *Levels) String() string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.spec()
+}
+
+// spec returns the spec of the current configuration, l.mu must be
+// held.
+func (l *Levels) spec() string {
+	parts := []string{l.min.String()}
+	for context, lvl := range l.contexts {
+		parts = append(parts, context+"="+lvl.String())
+	}
+	sort.Strings(parts[1:])
+	return strings.Join(parts, ",")
+}
+
+// Enabled reports whether a log at lvl, with the given `context`
+// field, should be logged.
+func (l *Levels) Enabled(lvl Level, context string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if min, ok := l.contexts[context]; ok {
+		return lvl >= min
+	}
+	return lvl >= l.min
+}
+
+// ToggleDebug switches to logging everything, down to trace level (eg.
+// SQL queries), or back to the previous configuration if ToggleDebug
+// was called before.
+func (l *Levels) ToggleDebug() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.toggled != "" {
+		l.min, l.contexts, _ = parseLevels(l.toggled)
+		l.toggled = ""
+		return
+	}
+
+	l.toggled = l.spec()
+	l.min, l.contexts = LevelTrace, map[string]Level{}
+}
+
+// DefaultLevels are the levels used by `Default` and `Human`
+// loggers, configured by the `APPKIT_LOG_LEVEL` environment
+// variable. All levels are logged if it is not set.
+var DefaultLevels = defaultLevels()
+
+var defaultLevelsErr error
+
+func defaultLevels() *Levels {
+	spec := os.Getenv(levelLogEnvName)
+	levels, err := NewLevels(spec)
+	if err != nil {
+		defaultLevelsErr = fmt.Errorf("invalid %s %q, logging all levels: %v", levelLogEnvName, spec, err)
+		levels, _ = NewLevels("")
+	}
+	return levels
+}
+
+type levelFilter struct {
+	next   log.Logger
+	levels *Levels
+}
+
+// NewLevelFilter returns a logger that drops logs that are disabled
+// by levels. Logs without a `level` field, or with an unknown level,
+// are always logged.
+func NewLevelFilter(next log.Logger, levels *Levels) log.Logger {
+	return levelFilter{next: next, levels: levels}
+}
+
+func (f levelFilter) Log(keyvals ...interface{}) error {
+	var context string
+	for i := 1; i < len(keyvals); i += 2 {
+		if keyvals[i-1] == "context" {
+			context = fmt.Sprint(keyvals[i])
+		}
+	}
+
+	if lvl, ok := LevelOf(keyvals...); ok && !f.levels.Enabled(lvl, context) {
+		return nil
+	}
+
+	return f.next.Log(keyvals...)
+}
+
+type levelHook struct {
+	next log.Logger
+	min  Level
+	hook func(keyvals []interface{})
+}
+
+// NewLevelHook returns a logger that calls hook with every log at min
+// level or above, before logging it to next.
+func NewLevelHook(next log.Logger, min Level, hook func(keyvals []interface{})) log.Logger {
+	return levelHook{next: next, min: min, hook: hook}
+}
+
+func (h levelHook) Log(keyvals ...interface{}) error {
+	if lvl, ok := LevelOf(keyvals...); ok && lvl >= h.min {
+		h.hook(keyvals)
+	}
+	return h.next.Log(keyvals...)
+}
+
+// LevelHandler is a HTTP handler to inspect and change levels at
+// runtime, meant to be mounted on an admin endpoint.
+//
+// `GET` responds with the current levels spec, `PUT` or `POST` set a
+// new spec from the `level` form value, or the request body.
+func LevelHandler(levels *Levels) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET", "HEAD":
+		case "PUT", "POST":
+			spec := r.FormValue("level")
+			if spec == "" {
+				body, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				spec = string(body)
+			}
+
+			if err := levels.Set(spec); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, levels.String())
+	})
+}
@@ -0,0 +1,125 @@
+package log_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	klog "github.com/go-kit/kit/log"
+	"github.com/theplant/appkit/log"
+)
+
+func TestLevelFilter(t *testing.T) {
+	levels, err := log.NewLevels("warn,gorm=debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := bytes.NewBuffer(nil)
+	l := log.Logger{Logger: log.NewLevelFilter(klog.LoggerFunc(func(keyvals ...interface{}) error {
+		fmt.Fprintln(output, keyvals...)
+		return nil
+	}), levels)}
+
+	l.Info().Log("msg", "dropped")
+	l.Warn().Log("msg", "kept warn")
+	l.With("context", "gorm").Debug().Log("msg", "kept gorm debug")
+	l.With("context", "http").Info().Log("msg", "dropped http info")
+	l.Log("msg", "kept no level")
+
+	expected := `level warn msg kept warn
+level debug context gorm msg kept gorm debug
+msg kept no level
+`
+	if got := output.String(); got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+
+	levels.ToggleDebug()
+	if !levels.Enabled(log.LevelTrace, "http") {
+		t.Errorf("expected trace enabled after toggle, got %s", levels)
+	}
+
+	levels.ToggleDebug()
+	if got, want := levels.String(), "warn,gorm=debug"; got != want {
+		t.Errorf("got %q after second toggle, want %q", got, want)
+	}
+}
+
+func TestToggleDebugConcurrently(t *testing.T) {
+	levels, _ := log.NewLevels("warn,gorm=debug")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			levels.ToggleDebug()
+		}()
+	}
+	wg.Wait()
+
+	if got, want := levels.String(), "warn,gorm=debug"; got != want {
+		t.Errorf("got %q after an even number of toggles, want %q", got, want)
+	}
+}
+
+func TestCritLevel(t *testing.T) {
+	levels, _ := log.NewLevels("crit")
+
+	output := bytes.NewBuffer(nil)
+	l := log.Logger{Logger: log.NewLevelFilter(klog.NewLogfmtLogger(output), levels)}
+
+	l.Error().Log("msg", "dropped")
+	l.Crit().Log("msg", "kept crit")
+	l.Fatal().Log("msg", "kept fatal")
+	l.Trace().Log("msg", "dropped")
+
+	expected := `level=crit msg="kept crit"
+level=fatal msg="kept fatal"
+`
+	if got := output.String(); got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+
+	if lvl, ok := log.LevelOf("msg", "x", "level", "crit"); !ok || lvl != log.LevelCrit || !(lvl > log.LevelError) {
+		t.Errorf("got %v %v, want crit above error", lvl, ok)
+	}
+}
+
+func TestNewLevelsInvalid(t *testing.T) {
+	if _, err := log.NewLevels("info,gorm=loud"); err == nil {
+		t.Error("expected error for invalid level")
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	levels, _ := log.NewLevels("info")
+	handler := log.LevelHandler(levels)
+
+	do := func(method, body string) (int, string) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, "/", strings.NewReader(body)))
+		return rec.Code, rec.Body.String()
+	}
+
+	if code, body := do("GET", ""); code != http.StatusOK || body != "info\n" {
+		t.Errorf("GET: got %d %q", code, body)
+	}
+
+	if code, body := do("PUT", "error,http=debug"); code != http.StatusOK || body != "error,http=debug\n" {
+		t.Errorf("PUT: got %d %q", code, body)
+	}
+
+	if code, _ := do("PUT", "chatty"); code != http.StatusBadRequest {
+		t.Errorf("PUT invalid: got %d", code)
+	}
+
+	if got, want := levels.String(), "error,http=debug"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"strings"
+	"sync"
 
 	stdl "log"
 
@@ -15,7 +16,18 @@ import (
 	"github.com/theplant/appkit/kerrs"
 )
 
-const humanLogEnvName = "APPKIT_LOG_HUMAN"
+const (
+	humanLogEnvName  = "APPKIT_LOG_HUMAN"
+	formatLogEnvName = "APPKIT_LOG_FORMAT"
+)
+
+// Log output formats, selected by the `APPKIT_LOG_FORMAT` environment
+// variable
+const (
+	FormatLogfmt = "logfmt"
+	FormatJSON   = "json"
+	FormatHuman  = "human"
+)
 
 type Logger struct {
 	log.Logger
@@ -56,6 +68,12 @@ func (l Logger) WithError(err error) log.Logger {
 	return l
 }
 
+// Trace logs at trace level, below debug, for very verbose logs.
+func (l Logger) Trace() log.Logger {
+	l.Logger = log.WithPrefix(l.Logger, level.Key(), traceValue)
+	return l
+}
+
 func (l Logger) Debug() log.Logger {
 	l.Logger = level.Debug(l.Logger)
 	return l
@@ -66,8 +84,17 @@ func (l Logger) Info() log.Logger {
 	return l
 }
 
+// Crit logs at crit level, above error, for errors that need
+// immediate attention.
 func (l Logger) Crit() log.Logger {
-	l.Logger = level.Error(l.Logger)
+	l.Logger = log.WithPrefix(l.Logger, level.Key(), critValue)
+	return l
+}
+
+// Fatal logs at fatal level, the highest level. It only sets the
+// level, it's up to the caller to exit the program.
+func (l Logger) Fatal() log.Logger {
+	l.Logger = log.WithPrefix(l.Logger, level.Key(), fatalValue)
 	return l
 }
 
@@ -81,24 +108,78 @@ func (l Logger) Warn() log.Logger {
 	return l
 }
 
+// Default returns a logger that writes to stdout in the format set
+// by `APPKIT_LOG_FORMAT` (`logfmt`, `json` or `human`), defaulting to
+// logfmt. If the format is not set, `APPKIT_LOG_HUMAN` can be used
+// to select the human format.
 func Default() Logger {
-	human := strings.ToLower(os.Getenv(humanLogEnvName))
-	if len(human) > 0 && human != "false" && human != "0" {
-		return Human()
+	return newDefault(nil)
+}
+
+// newDefault is Default, writing to w instead of the default writer
+// if w isn't nil.
+func newDefault(w io.Writer) Logger {
+	format := logFormat()
+	if format == FormatHuman {
+		return NewHuman(HumanOptions{Output: w})
+	}
+
+	if w == nil {
+		w = defaultWriter()
 	}
 
 	var timer log.Valuer = func() interface{} { return time.Now().Format(time.RFC3339Nano) }
 
-	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stdout))
+	var l log.Logger
+	if format == FormatJSON {
+		l = NewJSONLogger(w)
+	} else {
+		l = log.NewLogfmtLogger(w)
+	}
 
 	lg := Logger{
-		Logger: l,
+		Logger: NewLevelFilter(NewRedactingLogger(l, DefaultRedactor), DefaultLevels),
 	}
 	lg = lg.With("ts", timer, "caller", log.Caller(4))
 
+	warnDefaultConfig(lg)
+
 	return lg
 }
 
+var warnDefaultConfigOnce sync.Once
+
+// warnDefaultConfig logs (once) any errors configuring the default
+// loggers from the environment.
+func warnDefaultConfig(l Logger) {
+	warnDefaultConfigOnce.Do(func() {
+		for _, err := range []error{defaultLevelsErr, defaultWriterErr} {
+			if err != nil {
+				l.Warn().Log(
+					"context", "appkit/log.Default",
+					"err", err,
+					"msg", err.Error(),
+				)
+			}
+		}
+	})
+}
+
+func logFormat() string {
+	format := strings.ToLower(os.Getenv(formatLogEnvName))
+	switch format {
+	case FormatLogfmt, FormatJSON, FormatHuman:
+		return format
+	}
+
+	human := strings.ToLower(os.Getenv(humanLogEnvName))
+	if len(human) > 0 && human != "false" && human != "0" {
+		return FormatHuman
+	}
+
+	return FormatLogfmt
+}
+
 // NewNopLogger returns a logger that doesn't do anything. This just a wrapper of
 // `go-kit/log.nopLogger`.
 func NewNopLogger() Logger {
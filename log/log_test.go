@@ -35,9 +35,9 @@ var logErrCases = []struct {
 		err: kerrs.Wrapv(io.EOF, "wrong io", "testcase", "TestLogError", "lineno", 23),
 		expected: `
 level error testcase TestLogError lineno 23 msg wrong io: EOF stacktrace wrong io testcase=TestLogError lineno=23: EOF
-github.com/theplant/appkit/kerrs.Wrapv
-	github.com/theplant/appkit/kerrs/errors.go:27
-github.com/theplant/appkit/log_test.init`,
+github.com/theplant/appkit/log_test.init
+	github.com/theplant/appkit/log/log_test.go:35
+`,
 	},
 	{
 		err: errors.New("it's error"),
@@ -49,9 +49,9 @@ level error msg it's error
 		err: kerrs.Wrapv(io.EOF, "the message", "testcase", "TestLogError", "lineno"),
 		expected: `
 level error testcase TestLogError lineno <value-missing> msg the message: EOF stacktrace the message testcase=TestLogError lineno="<value-missing>": EOF
-github.com/theplant/appkit/kerrs.Wrapv
-	github.com/theplant/appkit/kerrs/errors.go:27
-github.com/theplant/appkit/log_test.init`,
+github.com/theplant/appkit/log_test.init
+	github.com/theplant/appkit/log/log_test.go:49
+`,
 	},
 }
 
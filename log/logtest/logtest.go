@@ -0,0 +1,226 @@
+// Package logtest provides a `log.Logger` for tests that captures log
+// entries, so that tests can make assertions on what was logged.
+package logtest
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	klog "github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/theplant/appkit/log"
+)
+
+// Entry is a captured log entry.
+type Entry struct {
+	// Level of the entry, "" if logged without a level
+	Level string
+	// Msg is the `msg` field of the entry
+	Msg string
+	// Keyvals are all fields of the entry, including `level` and `msg`
+	Keyvals []interface{}
+}
+
+// Value returns the (last) value of key in the entry.
+func (e Entry) Value(key string) (interface{}, bool) {
+	var val interface{}
+	var found bool
+	for i := 1; i < len(e.Keyvals); i += 2 {
+		if fmt.Sprint(e.Keyvals[i-1]) == key {
+			val, found = e.Keyvals[i], true
+		}
+	}
+	return val, found
+}
+
+// Matches reports whether the entry has all of keyvals. Values are
+// compared by their `fmt.Sprint` representation, so `1` matches `"1"`.
+func (e Entry) Matches(keyvals ...interface{}) bool {
+	for i := 1; i < len(keyvals); i += 2 {
+		val, ok := e.Value(fmt.Sprint(keyvals[i-1]))
+		if !ok || fmt.Sprint(val) != fmt.Sprint(keyvals[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e Entry) String() string {
+	buf := bytes.NewBuffer(nil)
+	klog.NewLogfmtLogger(buf).Log(e.Keyvals...)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// Option configures a Logger.
+type Option func(*Logger)
+
+// Quiet stops the logger from writing entries to `testing.T.Log`.
+func Quiet() Option {
+	return func(l *Logger) { l.quiet = true }
+}
+
+// FailOnError makes the test fail, when it finishes, if any entry was
+// logged at error level or above, unless it was expected with
+// `ExpectError`.
+func FailOnError() Option {
+	return func(l *Logger) { l.failOnError = true }
+}
+
+// Logger is a `log.Logger` that captures all entries logged via it,
+// or loggers derived from it (eg. with `With`).
+type Logger struct {
+	log.Logger
+
+	t           testing.TB
+	quiet       bool
+	failOnError bool
+
+	mu       sync.Mutex
+	entries  []Entry
+	expected [][]interface{}
+}
+
+// New returns a Logger for the test t. Entries are written to t.Log
+// (so they're shown with the right test when it fails, or with `go
+// test -v`), unless the Quiet option is used.
+func New(t testing.TB, opts ...Option) *Logger {
+	l := &Logger{t: t}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	l.Logger = log.Logger{Logger: klog.LoggerFunc(l.record)}
+
+	if l.failOnError {
+		t.Cleanup(l.checkErrors)
+	}
+
+	return l
+}
+
+func (l *Logger) record(keyvals ...interface{}) error {
+	keyvals = append([]interface{}{}, keyvals...)
+	if len(keyvals)%2 == 1 {
+		keyvals = append(keyvals, klog.ErrMissingValue)
+	}
+
+	e := Entry{Keyvals: keyvals}
+	if lvl, ok := e.Value(fmt.Sprint(level.Key())); ok {
+		e.Level = fmt.Sprint(lvl)
+	}
+	if msg, ok := e.Value("msg"); ok {
+		e.Msg = fmt.Sprint(msg)
+	}
+
+	l.mu.Lock()
+	l.entries = append(l.entries, e)
+	l.mu.Unlock()
+
+	if !l.quiet {
+		l.t.Log(e.String())
+	}
+	return nil
+}
+
+// Entries returns all captured entries.
+func (l *Logger) Entries() []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Entry{}, l.entries...)
+}
+
+// Reset discards all captured entries.
+func (l *Logger) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = nil
+}
+
+// Find returns entries that have all of keyvals, eg. `Find("level",
+// "error", "during", "db.Save")`.
+func (l *Logger) Find(keyvals ...interface{}) []Entry {
+	var found []Entry
+	for _, e := range l.Entries() {
+		if e.Matches(keyvals...) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// FindLevel returns entries at level lvl that have all of keyvals.
+func (l *Logger) FindLevel(lvl log.Level, keyvals ...interface{}) []Entry {
+	return l.Find(append([]interface{}{level.Key(), lvl}, keyvals...)...)
+}
+
+// AssertLogged fails the test if no entry at level lvl has all of
+// keyvals.
+func (l *Logger) AssertLogged(lvl log.Level, keyvals ...interface{}) {
+	l.t.Helper()
+	if len(l.FindLevel(lvl, keyvals...)) == 0 {
+		l.t.Errorf("expected %s log with %v, got:\n%s", lvl, keyvals, l.dump())
+	}
+}
+
+// AssertNotLogged fails the test if any entry has all of keyvals.
+func (l *Logger) AssertNotLogged(keyvals ...interface{}) {
+	l.t.Helper()
+	if found := l.Find(keyvals...); len(found) > 0 {
+		l.t.Errorf("expected no log with %v, got:\n%s", keyvals, dump(found))
+	}
+}
+
+// ExpectError declares that entries at error level or above with all
+// of keyvals are expected, so they don't fail the test with the
+// FailOnError option.
+func (l *Logger) ExpectError(keyvals ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expected = append(l.expected, keyvals)
+}
+
+func (l *Logger) checkErrors() {
+	l.t.Helper()
+
+	l.mu.Lock()
+	expected := l.expected
+	l.mu.Unlock()
+
+	var unexpected []Entry
+	for _, e := range l.Entries() {
+		lvl, err := log.ParseLevel(e.Level)
+		if err != nil || lvl < log.LevelError {
+			continue
+		}
+
+		ok := false
+		for _, keyvals := range expected {
+			if e.Matches(keyvals...) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			unexpected = append(unexpected, e)
+		}
+	}
+
+	if len(unexpected) > 0 {
+		l.t.Errorf("unexpected error logs:\n%s", dump(unexpected))
+	}
+}
+
+func (l *Logger) dump() string {
+	return dump(l.Entries())
+}
+
+func dump(entries []Entry) string {
+	var lines []string
+	for _, e := range entries {
+		lines = append(lines, "\t"+e.String())
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,74 @@
+package logtest_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/theplant/appkit/log"
+	"github.com/theplant/appkit/log/logtest"
+)
+
+func TestLogger(t *testing.T) {
+	l := logtest.New(t, logtest.FailOnError())
+
+	l.With("context", "orders").Info().Log("msg", "order placed", "order_id", 123)
+	l.Warn().Log("msg", "slow payment")
+
+	l.ExpectError("during", "payment.Charge")
+	l.Error().Log("msg", "payment failed", "during", "payment.Charge", "err", errors.New("declined"))
+
+	l.AssertLogged(log.LevelInfo, "context", "orders", "order_id", "123")
+	l.AssertLogged(log.LevelError, "msg", "payment failed")
+	l.AssertNotLogged("level", "debug")
+
+	entries := l.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+
+	if e := entries[0]; e.Level != "info" || e.Msg != "order placed" {
+		t.Errorf("got entry %+v", e)
+	}
+
+	if got, want := entries[2].String(), "level=error msg=\"payment failed\" during=payment.Charge err=declined"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if got := len(l.FindLevel(log.LevelWarn)); got != 1 {
+		t.Errorf("got %d warn entries, want 1", got)
+	}
+
+	l.Reset()
+	if got := len(l.Entries()); got != 0 {
+		t.Errorf("got %d entries after reset", got)
+	}
+}
+
+func TestFailOnError(t *testing.T) {
+	ft := &fakeT{T: t}
+	func() {
+		l := logtest.New(ft, logtest.Quiet(), logtest.FailOnError())
+		l.Error().Log("msg", "unexpected")
+	}()
+	ft.cleanup()
+
+	if !ft.failed {
+		t.Error("expected test to fail on unexpected error log")
+	}
+}
+
+// fakeT records failures instead of failing the test
+type fakeT struct {
+	*testing.T
+	failed   bool
+	cleanups []func()
+}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) { f.failed = true }
+func (f *fakeT) Cleanup(c func())                          { f.cleanups = append(f.cleanups, c) }
+
+func (f *fakeT) cleanup() {
+	for _, c := range f.cleanups {
+		c()
+	}
+}
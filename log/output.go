@@ -0,0 +1,84 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+)
+
+const asyncLogEnvName = "APPKIT_LOG_ASYNC"
+
+var (
+	defaultWriterOnce  sync.Once
+	defaultOutput      io.Writer
+	defaultAsyncWriter *AsyncWriter
+	defaultWriterErr   error
+
+	// whether the default writer writes to stdout and stdout is a
+	// terminal
+	defaultTerminal bool
+)
+
+// defaultWriter returns the writer shared by `Default` and `Human`
+// loggers: stdout, or the file set by `APPKIT_LOG_FILE`, written
+// asynchronously if `APPKIT_LOG_ASYNC` is set to `block` (or `true`)
+// or `drop`.
+func defaultWriter() io.Writer {
+	defaultWriterOnce.Do(func() {
+		var w io.Writer = os.Stdout
+
+		if config, ok, err := fileConfigFromEnv(); err != nil {
+			defaultWriterErr = fmt.Errorf("%v, logging to stdout", err)
+		} else if ok {
+			if fw, err := NewFileWriter(config); err != nil {
+				defaultWriterErr = fmt.Errorf("%v, logging to stdout", err)
+			} else {
+				ReopenOnSignal(fw)
+				w = fw
+			}
+		}
+
+		defaultTerminal = w == os.Stdout && isTerminal(os.Stdout)
+
+		switch strings.ToLower(os.Getenv(asyncLogEnvName)) {
+		case "block", "true", "1":
+			defaultAsyncWriter = NewAsyncWriter(w, AsyncConfig{Policy: AsyncBlock})
+		case "drop":
+			defaultAsyncWriter = NewAsyncWriter(w, AsyncConfig{Policy: AsyncDrop})
+		}
+
+		if defaultAsyncWriter != nil {
+			defaultOutput = defaultAsyncWriter
+		} else {
+			defaultOutput = log.NewSyncWriter(w)
+		}
+	})
+	return defaultOutput
+}
+
+func defaultWriterIsTerminal() bool {
+	defaultWriter()
+	return defaultTerminal
+}
+
+// DefaultAsyncWriter returns the AsyncWriter used by `Default` and
+// `Human` loggers when `APPKIT_LOG_ASYNC` is set, eg. to monitor
+// dropped lines.
+func DefaultAsyncWriter() (*AsyncWriter, bool) {
+	defaultWriter()
+	return defaultAsyncWriter, defaultAsyncWriter != nil
+}
+
+// Flush waits for any logs buffered by `Default` and `Human` loggers
+// to be written. Call it during graceful shutdown, before the program
+// exits.
+func Flush() error {
+	if w, ok := DefaultAsyncWriter(); ok {
+		return w.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package log
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+)
+
+const redactKeysLogEnvName = "APPKIT_LOG_REDACT_KEYS"
+
+// Redacted is logged in place of redacted values.
+const Redacted = "[REDACTED]"
+
+// Secret is a string that is always logged (and printed) as
+// `[REDACTED]`, eg. `logger.Log("token", log.Secret(token))`.
+type Secret string
+
+func (Secret) String() string { return Redacted }
+
+// GoString is part of fmt.GoStringer, used for `%#v`
+func (Secret) GoString() string { return Redacted }
+
+// Format is part of fmt.Formatter, so that no verb prints the secret
+func (Secret) Format(f fmt.State, verb rune) { fmt.Fprint(f, Redacted) }
+
+// MarshalText is part of encoding.TextMarshaler, used by logfmt and
+// JSON encoders
+func (Secret) MarshalText() ([]byte, error) { return []byte(Redacted), nil }
+
+// DefaultRedactKeys returns the keys redacted by `DefaultRedactor`,
+// besides the ones of `APPKIT_LOG_REDACT_KEYS`, eg. to extend them in
+// a custom Redactor.
+func DefaultRedactKeys() []string {
+	return append([]string{}, defaultRedactKeys...)
+}
+
+// DefaultRedactPattern returns the pattern of keys redacted by
+// `DefaultRedactor`, besides `DefaultRedactKeys()`: keys containing
+// eg. "password", "secret", "token", "api_key", "session" or
+// "private_key" (so also "X-Api-Key", "X-CSRF-Token" or
+// "client_secret"), or "auth" as a word (eg. "X-Auth-User", but not
+// "author").
+func DefaultRedactPattern() *regexp.Regexp {
+	return defaultRedactPattern
+}
+
+var defaultRedactPattern = regexp.MustCompile(`(?i)(passw(or)?d|secret|token|api[_-]?key|(^|[_-])auth($|[_-])|authorization|cookie|session|private[_-]?key|credential)`)
+
+var defaultRedactKeys = []string{
+	"password",
+	"passwd",
+	"secret",
+	"token",
+	"access_token",
+	"refresh_token",
+	"api_key",
+	"authorization",
+	"proxy_authorization",
+	"cookie",
+	"set_cookie",
+}
+
+// Redactor decides which log keys (and HTTP headers) have sensitive
+// values that must not be logged.
+type Redactor struct {
+	keys    map[string]bool
+	pattern *regexp.Regexp
+}
+
+// NewRedactor returns a Redactor that redacts values of any of keys,
+// or of keys matching pattern (if not nil). Keys are compared
+// case-insensitively, with `-` and `_` considered equal, so that the
+// "set_cookie" key also matches the "Set-Cookie" HTTP header.
+func NewRedactor(keys []string, pattern *regexp.Regexp) *Redactor {
+	r := &Redactor{keys: map[string]bool{}, pattern: pattern}
+	for _, key := range keys {
+		r.keys[normalizeRedactKey(key)] = true
+	}
+	return r
+}
+
+func normalizeRedactKey(key string) string {
+	return strings.Replace(strings.ToLower(strings.TrimSpace(key)), "-", "_", -1)
+}
+
+// Redact reports whether values of key must be redacted.
+func (r *Redactor) Redact(key string) bool {
+	if r.keys[normalizeRedactKey(key)] {
+		return true
+	}
+	return r.pattern != nil && r.pattern.MatchString(key)
+}
+
+// RedactHeader returns a copy of h with the values of sensitive
+// headers redacted.
+func (r *Redactor) RedactHeader(h http.Header) http.Header {
+	redacted := make(http.Header, len(h))
+	for name, values := range h {
+		if r.Redact(name) {
+			values = []string{Redacted}
+		}
+		redacted[name] = values
+	}
+	return redacted
+}
+
+// redactValue redacts sensitive keys of maps with string keys (eg.
+// `http.Header` or `url.Values`), returning val unchanged, and false,
+// if nothing needs redacting.
+func (r *Redactor) redactValue(val interface{}) (interface{}, bool) {
+	if h, ok := val.(http.Header); ok {
+		for name := range h {
+			if r.Redact(name) {
+				return r.RedactHeader(h), true
+			}
+		}
+		return val, false
+	}
+
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return val, false
+	}
+
+	changed := false
+	redacted := make(map[string]interface{}, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		key := iter.Key().String()
+		v := iter.Value().Interface()
+		if r.Redact(key) {
+			v, changed = Redacted, true
+		} else if nested, ok := r.redactValue(v); ok {
+			v, changed = nested, true
+		}
+		redacted[key] = v
+	}
+
+	if !changed {
+		return val, false
+	}
+	return redacted, true
+}
+
+// DefaultRedactor is used by `Default` and `Human` loggers, and
+// redacts `DefaultRedactKeys()`, keys matching
+// `DefaultRedactPattern()`, plus any keys in the comma-separated
+// `APPKIT_LOG_REDACT_KEYS` environment variable.
+var DefaultRedactor = defaultRedactor()
+
+func defaultRedactor() *Redactor {
+	keys := DefaultRedactKeys()
+	for _, key := range strings.Split(os.Getenv(redactKeysLogEnvName), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return NewRedactor(keys, DefaultRedactPattern())
+}
+
+type redactingLogger struct {
+	next     log.Logger
+	redactor *Redactor
+}
+
+// NewRedactingLogger returns a logger that replaces the values of
+// sensitive keys with `[REDACTED]`, including keys nested in maps,
+// before logging to next.
+func NewRedactingLogger(next log.Logger, r *Redactor) log.Logger {
+	return redactingLogger{next: next, redactor: r}
+}
+
+func (l redactingLogger) Log(keyvals ...interface{}) error {
+	redacted := make([]interface{}, len(keyvals))
+	for i := 0; i < len(keyvals); i += 2 {
+		redacted[i] = keyvals[i]
+		if i+1 >= len(keyvals) {
+			break
+		}
+
+		if l.redactor.Redact(fmt.Sprint(keyvals[i])) {
+			redacted[i+1] = Redacted
+		} else {
+			redacted[i+1], _ = l.redactor.redactValue(keyvals[i+1])
+		}
+	}
+	return l.next.Log(redacted...)
+}
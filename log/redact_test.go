@@ -0,0 +1,65 @@
+package log_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/theplant/appkit/log"
+)
+
+func TestRedactingLogger(t *testing.T) {
+	output := bytes.NewBuffer(nil)
+	r := log.NewRedactor(log.DefaultRedactKeys(), regexp.MustCompile(`(?i)_key$`))
+	l := log.Logger{Logger: log.NewRedactingLogger(log.NewJSONLogger(output), r)}
+
+	l.With("Password", "hunter2").Log(
+		"msg", "hello",
+		"stripe_key", "sk_live",
+		"api_token", log.Secret("abc"),
+		"params", map[string]interface{}{"user": "bob", "token": "xyz"},
+		"header", http.Header{"Authorization": {"Bearer abc"}, "Accept": {"*/*"}},
+	)
+
+	expected := `{"Password":"[REDACTED]","msg":"hello","stripe_key":"[REDACTED]","api_token":"[REDACTED]","params":{"token":"[REDACTED]","user":"bob"},"header":{"Accept":["*/*"],"Authorization":["[REDACTED]"]}}` + "\n"
+	if got := output.String(); got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestDefaultRedactor(t *testing.T) {
+	for key, redacted := range map[string]bool{
+		"password":      true,
+		"new_password":  true,
+		"X-Api-Key":     true,
+		"apikey":        true,
+		"X-Auth-Token":  true,
+		"X-CSRF-Token":  true,
+		"client_secret": true,
+		"private_key":   true,
+		"session":       true,
+		"Set-Cookie":    true,
+		"Authorization": true,
+		"auth":          true,
+		"author":        false,
+		"user_id":       false,
+		"passenger":     false,
+		"path":          false,
+		"request_us":    false,
+	} {
+		if got := log.DefaultRedactor.Redact(key); got != redacted {
+			t.Errorf("Redact(%q) = %v, want %v", key, got, redacted)
+		}
+	}
+}
+
+func TestSecret(t *testing.T) {
+	s := log.Secret("hunter2")
+	for _, format := range []string{"%v", "%s", "%q", "%#v", "%x", "%+v"} {
+		if got := fmt.Sprintf(format, s); got != log.Redacted {
+			t.Errorf("got %q for %s", got, format)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// SamplerConfig configures a Sampler. Zero values are replaced by the
+// defaults noted for each field.
+type SamplerConfig struct {
+	// Interval is the sampling period, counts of logged lines are
+	// reset, and dropped lines reported, every Interval. Defaults to
+	// 1s.
+	Interval time.Duration
+
+	// First lines with the same `msg` and `context` are logged in
+	// every interval. Defaults to 100.
+	First int
+
+	// Thereafter every Thereafter-th line is logged, the rest are
+	// dropped. Defaults to 100.
+	Thereafter int
+
+	// AlwaysKeep is the minimum level that is never sampled, eg.
+	// `log.LevelPtr(log.LevelTrace)` to never sample. Defaults to
+	// LevelWarn when nil.
+	AlwaysKeep *Level
+}
+
+// LevelPtr returns a pointer to lvl, eg. for
+// `SamplerConfig.AlwaysKeep`.
+func LevelPtr(lvl Level) *Level {
+	return &lvl
+}
+
+type sampleKey struct {
+	msg, context string
+}
+
+type sampleCounter struct {
+	count   int
+	dropped int
+}
+
+// Sampler is a logger that samples logs of hot paths, keyed by their
+// `msg` and `context` fields: in every interval, the first N lines
+// with the same key are logged, then only every Mth line. The number
+// of dropped lines per key is logged every interval.
+type Sampler struct {
+	next   log.Logger
+	config SamplerConfig
+
+	mu       sync.Mutex
+	counters map[sampleKey]*sampleCounter
+
+	done chan struct{}
+	once sync.Once
+}
+
+// NewSampler returns a Sampler that logs sampled logs to next. Call
+// `Close` to stop the sampler and report any dropped lines.
+func NewSampler(next log.Logger, config SamplerConfig) *Sampler {
+	if config.Interval <= 0 {
+		config.Interval = time.Second
+	}
+	if config.First <= 0 {
+		config.First = 100
+	}
+	if config.Thereafter <= 0 {
+		config.Thereafter = 100
+	}
+	if config.AlwaysKeep == nil {
+		config.AlwaysKeep = LevelPtr(LevelWarn)
+	}
+
+	s := &Sampler{
+		next:     next,
+		config:   config,
+		counters: map[sampleKey]*sampleCounter{},
+		done:     make(chan struct{}),
+	}
+
+	go s.tick()
+
+	return s
+}
+
+// Log is part of go-kit's log.Logger
+func (s *Sampler) Log(keyvals ...interface{}) error {
+	if lvl, ok := LevelOf(keyvals...); ok && lvl >= *s.config.AlwaysKeep {
+		return s.next.Log(keyvals...)
+	}
+
+	var key sampleKey
+	for i := 1; i < len(keyvals); i += 2 {
+		switch keyvals[i-1] {
+		case "msg":
+			key.msg = fmt.Sprint(keyvals[i])
+		case "context":
+			key.context = fmt.Sprint(keyvals[i])
+		}
+	}
+
+	s.mu.Lock()
+	c, ok := s.counters[key]
+	if !ok {
+		c = &sampleCounter{}
+		s.counters[key] = c
+	}
+	c.count++
+	keep := c.count <= s.config.First || (c.count-s.config.First)%s.config.Thereafter == 0
+	if !keep {
+		c.dropped++
+	}
+	s.mu.Unlock()
+
+	if !keep {
+		return nil
+	}
+	return s.next.Log(keyvals...)
+}
+
+func (s *Sampler) tick() {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// flush resets the counters, and logs how many lines were dropped
+// for each key.
+func (s *Sampler) flush() {
+	s.mu.Lock()
+	counters := s.counters
+	s.counters = map[sampleKey]*sampleCounter{}
+	s.mu.Unlock()
+
+	for key, c := range counters {
+		if c.dropped == 0 {
+			continue
+		}
+
+		s.next.Log(
+			level.Key(), level.InfoValue(),
+			"context", "appkit/log.Sampler",
+			"msg", fmt.Sprintf("dropped %d sampled log lines", c.dropped),
+			"dropped", c.dropped,
+			"sampled_msg", key.msg,
+			"sampled_context", key.context,
+			"interval", s.config.Interval,
+		)
+	}
+}
+
+// Close stops the sampler, and logs counts of dropped lines since
+// the last interval.
+func (s *Sampler) Close() error {
+	s.once.Do(func() {
+		close(s.done)
+		s.flush()
+	})
+	return nil
+}
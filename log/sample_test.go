@@ -0,0 +1,69 @@
+package log_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	klog "github.com/go-kit/kit/log"
+	"github.com/theplant/appkit/log"
+)
+
+func TestSampler(t *testing.T) {
+	output := bytes.NewBuffer(nil)
+	sampler := log.NewSampler(klog.NewLogfmtLogger(output), log.SamplerConfig{
+		Interval:   time.Hour,
+		First:      2,
+		Thereafter: 3,
+	})
+	l := log.Logger{Logger: sampler}
+
+	for i := 1; i <= 10; i++ {
+		l.Info().Log("msg", "hot path", "i", i)
+		l.With("context", "other").Info().Log("msg", "hot path", "i", i)
+	}
+	l.Error().Log("msg", "hot path", "i", "error")
+
+	sampler.Close()
+
+	var hot []string
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		if strings.Contains(line, ` msg="hot path"`) && !strings.Contains(line, "context=other") {
+			hot = append(hot, line[strings.LastIndex(line, "=")+1:])
+		}
+	}
+
+	// first 2, then every 3rd (5, 8), errors are always kept
+	if got, want := fmt.Sprint(hot), "[1 2 5 8 error]"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	for _, context := range []string{"", "other"} {
+		report := fmt.Sprintf(`msg="dropped 6 sampled log lines" dropped=6 sampled_msg="hot path" sampled_context=%s`, context)
+		if !strings.Contains(output.String(), report) {
+			t.Errorf("expected output to include %q, got:\n%s", report, output)
+		}
+	}
+}
+
+func TestSamplerAlwaysKeepTrace(t *testing.T) {
+	output := bytes.NewBuffer(nil)
+	sampler := log.NewSampler(klog.NewLogfmtLogger(output), log.SamplerConfig{
+		Interval:   time.Hour,
+		First:      1,
+		Thereafter: 100,
+		AlwaysKeep: log.LevelPtr(log.LevelTrace),
+	})
+	l := log.Logger{Logger: sampler}
+
+	for i := 0; i < 5; i++ {
+		l.Trace().Log("msg", "hot path")
+	}
+	sampler.Close()
+
+	if got := strings.Count(output.String(), `msg="hot path"`); got != 5 {
+		t.Errorf("got %d lines, want all 5 kept:\n%s", got, output)
+	}
+}
@@ -0,0 +1,47 @@
+//go:build !windows
+// +build !windows
+
+package log
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ToggleDebugOnSignal toggles debug (and trace) logging for levels (see
+// `Levels.ToggleDebug`) whenever the process receives `SIGUSR1`, eg.
+// `kill -USR1 <pid>`. Call the returned function to stop handling the
+// signal.
+func ToggleDebugOnSignal(levels *Levels) (stop func()) {
+	return onSignal(syscall.SIGUSR1, levels.ToggleDebug)
+}
+
+// ReopenOnSignal reopens w whenever the process receives `SIGHUP`, so
+// that external tools like logrotate can move the log file. Call the
+// returned function to stop handling the signal.
+func ReopenOnSignal(w *FileWriter) (stop func()) {
+	return onSignal(syscall.SIGHUP, func() { w.Reopen() })
+}
+
+func onSignal(sig os.Signal, f func()) (stop func()) {
+	c := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(c, sig)
+
+	go func() {
+		for {
+			select {
+			case <-c:
+				f()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(c)
+		close(done)
+	}
+}
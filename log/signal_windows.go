@@ -0,0 +1,11 @@
+package log
+
+// ToggleDebugOnSignal does nothing on Windows, which has no `SIGUSR1`.
+func ToggleDebugOnSignal(levels *Levels) (stop func()) {
+	return func() {}
+}
+
+// ReopenOnSignal does nothing on Windows, which has no `SIGHUP`.
+func ReopenOnSignal(w *FileWriter) (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,252 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// LevelCritical is the `log/slog` level mapped to appkit's crit
+// level, there's no equivalent in `log/slog`.
+const LevelCritical = slog.LevelError + 4
+
+// SlogHandler is a `log/slog.Handler` that writes into an appkit
+// Logger, so that libraries using `log/slog` log like the rest of the
+// app.
+//
+// If the context passed to the handler has a Logger (see `Context`),
+// that logger is used instead of the handler's, so that slog calls
+// with a request's context (eg. `slog.InfoContext(r.Context(), ...)`)
+// include the request's fields.
+type SlogHandler struct {
+	logger Logger
+	level  slog.Leveler
+
+	// attrs added by WithAttrs, already prefixed by their groups
+	keyvals []interface{}
+	prefix  string
+}
+
+// NewSlogHandler returns a SlogHandler that writes to l, for records
+// at level or above (nil means all records).
+func NewSlogHandler(l Logger, level slog.Leveler) *SlogHandler {
+	if level == nil {
+		level = slog.Level(-1 << 31)
+	}
+	return &SlogHandler{logger: l, level: level}
+}
+
+// Slog returns a `*slog.Logger` that writes into l.
+func Slog(l Logger) *slog.Logger {
+	return slog.New(NewSlogHandler(l, nil))
+}
+
+// Enabled is part of slog.Handler
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle is part of slog.Handler
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	l, ok := FromContext(ctx)
+	if !ok {
+		l = h.logger
+	}
+
+	keyvals := append([]interface{}{}, h.keyvals...)
+	r.Attrs(func(a slog.Attr) bool {
+		keyvals = appendSlogAttr(keyvals, h.prefix, a)
+		return true
+	})
+
+	return levelLogger(l, slogLevel(r.Level)).Log(append(keyvals, "msg", r.Message)...)
+}
+
+// WithAttrs is part of slog.Handler
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.keyvals = append([]interface{}{}, h.keyvals...)
+	for _, a := range attrs {
+		h2.keyvals = appendSlogAttr(h2.keyvals, h.prefix, a)
+	}
+	return &h2
+}
+
+// WithGroup is part of slog.Handler, attributes in groups are logged
+// with keys prefixed by the group names, eg. `request.method`.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+func appendSlogAttr(keyvals []interface{}, prefix string, a slog.Attr) []interface{} {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return keyvals
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if a.Key != "" {
+			groupPrefix = prefix + a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			keyvals = appendSlogAttr(keyvals, groupPrefix, ga)
+		}
+		return keyvals
+	}
+
+	return append(keyvals, prefix+a.Key, a.Value.Any())
+}
+
+// slogLevel maps slog levels to appkit levels.
+func slogLevel(l slog.Level) Level {
+	switch {
+	case l < slog.LevelDebug:
+		return LevelTrace
+	case l < slog.LevelInfo:
+		return LevelDebug
+	case l < slog.LevelWarn:
+		return LevelInfo
+	case l < slog.LevelError:
+		return LevelWarn
+	case l < LevelCritical:
+		return LevelError
+	default:
+		return LevelCrit
+	}
+}
+
+// levelLogger returns the go-kit logger of l at level lvl.
+func levelLogger(l Logger, lvl Level) log.Logger {
+	switch lvl {
+	case LevelTrace:
+		return l.Trace()
+	case LevelDebug:
+		return l.Debug()
+	case LevelInfo:
+		return l.Info()
+	case LevelWarn:
+		return l.Warn()
+	case LevelError:
+		return l.Error()
+	case LevelCrit:
+		return l.Crit()
+	default:
+		return l.Fatal()
+	}
+}
+
+type slogLogger struct {
+	ctx    context.Context
+	logger *slog.Logger
+}
+
+// FromSlog returns a Logger that writes into a `*slog.Logger`. The
+// `level` and `msg` fields are used for the record's level and
+// message, other fields are added as attributes. Records have the
+// source location of the call to `Log`.
+//
+// Records are handled with `context.Background()`, use
+// `FromSlogContext` for handlers that read values from the context.
+func FromSlog(l *slog.Logger) Logger {
+	return FromSlogContext(context.Background(), l)
+}
+
+// FromSlogContext is like `FromSlog`, but handles records with ctx,
+// eg. a request's context.
+func FromSlogContext(ctx context.Context, l *slog.Logger) Logger {
+	return Logger{Logger: slogLogger{ctx: ctx, logger: l}}
+}
+
+func (l slogLogger) Log(keyvals ...interface{}) error {
+	lvl := slog.LevelInfo
+	var msg string
+	var attrs []interface{}
+
+	for i := 0; i < len(keyvals); i += 2 {
+		key := keyvals[i]
+		var val interface{} = log.ErrMissingValue
+		if i+1 < len(keyvals) {
+			val = keyvals[i+1]
+		}
+
+		switch key {
+		case level.Key():
+			if appkitLevel, err := ParseLevel(fmt.Sprint(val)); err == nil {
+				lvl = toSlogLevel(appkitLevel)
+				continue
+			}
+		case "msg":
+			msg = fmt.Sprint(val)
+			continue
+		}
+
+		attrs = append(attrs, slog.Any(fmt.Sprint(key), val))
+	}
+
+	handler := l.logger.Handler()
+	if !handler.Enabled(l.ctx, lvl) {
+		return nil
+	}
+
+	r := slog.NewRecord(time.Now(), lvl, msg, callerPC())
+	r.Add(attrs...)
+	return handler.Handle(l.ctx, r)
+}
+
+// callerPC returns the program counter of the first caller outside
+// of go-kit's and appkit's log packages, ie. the call to `Log`.
+func callerPC() uintptr {
+	var pcs [32]uintptr
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !isLogFrame(frame.Function) {
+			return frame.PC
+		}
+		if !more {
+			return 0
+		}
+	}
+}
+
+func isLogFrame(function string) bool {
+	for _, pkg := range []string{"github.com/go-kit/kit/log", "github.com/theplant/appkit/log"} {
+		if strings.HasPrefix(function, pkg+".") || strings.HasPrefix(function, pkg+"/level.") {
+			return true
+		}
+	}
+	return false
+}
+
+// toSlogLevel maps appkit levels to slog levels.
+func toSlogLevel(l Level) slog.Level {
+	switch l {
+	case LevelTrace:
+		return slog.LevelDebug - 4
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelInfo:
+		return slog.LevelInfo
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	case LevelCrit:
+		return LevelCritical
+	default:
+		return LevelCritical + 4
+	}
+}
@@ -0,0 +1,97 @@
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	klog "github.com/go-kit/kit/log"
+	"github.com/theplant/appkit/log"
+)
+
+func TestSlogHandler(t *testing.T) {
+	output := bytes.NewBuffer(nil)
+	l := log.Logger{Logger: klog.NewLogfmtLogger(output)}
+
+	sl := log.Slog(l).With("lib", "x").WithGroup("req")
+	sl.Info("hello", "method", "GET", slog.Group("user", "id", 1))
+	sl.Log(context.Background(), log.LevelCritical, "boom")
+
+	// Logger from the context is used instead
+	ctxLogger := log.Logger{Logger: klog.NewLogfmtLogger(output)}.With("req_id", "abc")
+	sl.WarnContext(log.Context(context.Background(), ctxLogger), "from context")
+
+	expected := `level=info lib=x req.method=GET req.user.id=1 msg=hello
+level=crit lib=x msg=boom
+level=warn req_id=abc lib=x msg="from context"
+`
+	if got := output.String(); got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestFromSlog(t *testing.T) {
+	output := bytes.NewBuffer(nil)
+	sl := slog.New(slog.NewTextHandler(output, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	l := log.FromSlog(sl).With("context", "test")
+	l.Warn().Log("msg", "hello", "n", 1)
+	l.Crit().Log("msg", "boom")
+
+	expected := `level=WARN msg=hello context=test n=1
+level=ERROR+4 msg=boom context=test
+`
+	if got := output.String(); got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+type ctxKey struct{}
+
+// ctxHandler adds the ctxKey value of the context to records
+type ctxHandler struct {
+	slog.Handler
+}
+
+func (h ctxHandler) Handle(ctx context.Context, r slog.Record) error {
+	if v, ok := ctx.Value(ctxKey{}).(string); ok {
+		r.AddAttrs(slog.String("req_id", v))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func TestFromSlogContext(t *testing.T) {
+	output := bytes.NewBuffer(nil)
+	sl := slog.New(ctxHandler{slog.NewTextHandler(output, &slog.HandlerOptions{
+		AddSource: true,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			switch a.Key {
+			case slog.TimeKey:
+				return slog.Attr{}
+			case slog.SourceKey:
+				src := a.Value.Any().(*slog.Source)
+				return slog.String("source", filepath.Base(src.File))
+			}
+			return a
+		},
+	})})
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "abc")
+	log.FromSlogContext(ctx, sl).With("context", "test").Info().Log("msg", "hello")
+
+	expected := `level=INFO source=slog_test.go msg=hello context=test req_id=abc
+`
+	if got := output.String(); got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+}
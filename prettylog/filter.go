@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/theplant/appkit/log"
+)
+
+// match is a `key=value` or `key!=value` expression given with `-match`.
+type match struct {
+	key    string
+	value  string
+	negate bool
+}
+
+func parseMatch(s string) (match, error) {
+	if i := strings.Index(s, "!="); i > 0 {
+		return match{key: s[:i], value: s[i+2:], negate: true}, nil
+	}
+	if i := strings.Index(s, "="); i > 0 {
+		return match{key: s[:i], value: s[i+1:]}, nil
+	}
+	return match{}, fmt.Errorf("invalid match %q, expected key=value or key!=value", s)
+}
+
+func (m match) matches(data kvs) bool {
+	val, ok := data.get(m.key)
+	return (ok && val == m.value) != m.negate
+}
+
+// matches is a flag.Value for repeated `-match` flags.
+type matches []match
+
+func (m *matches) String() string {
+	var s []string
+	for _, mm := range *m {
+		op := "="
+		if mm.negate {
+			op = "!="
+		}
+		s = append(s, mm.key+op+mm.value)
+	}
+	return strings.Join(s, ",")
+}
+
+func (m *matches) Set(s string) error {
+	mm, err := parseMatch(s)
+	if err != nil {
+		return err
+	}
+	*m = append(*m, mm)
+	return nil
+}
+
+// filter selects the log lines to print.
+type filter struct {
+	minLevel *log.Level
+	reqID    string
+	matches  matches
+}
+
+func (f filter) active() bool {
+	return f.minLevel != nil || f.reqID != "" || len(f.matches) > 0
+}
+
+// keep reports whether a parsed line passes the filter. Lines without
+// a (known) level pass the level filter.
+func (f filter) keep(data kvs) bool {
+	if f.minLevel != nil {
+		if val, ok := data.get("level"); ok {
+			if lvl, err := log.ParseLevel(val); err == nil && lvl < *f.minLevel {
+				return false
+			}
+		}
+	}
+
+	if f.reqID != "" {
+		if val, _ := data.get("req_id"); val != f.reqID {
+			return false
+		}
+	}
+
+	for _, m := range f.matches {
+		if !m.matches(data) {
+			return false
+		}
+	}
+
+	return true
+}
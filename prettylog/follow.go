@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// followInterval is how often a followed file is checked for new
+// lines at EOF.
+const followInterval = 250 * time.Millisecond
+
+// readFiles sends the lines of files ("-" for stdin) to lines, and
+// closes lines once all files are read. Files are read one after
+// another, or concurrently if following them, as followed files never
+// end. It reports whether any file couldn't be read.
+func readFiles(files []string, follow bool, lines chan<- []byte) (failed bool) {
+	defer close(lines)
+
+	var mu sync.Mutex
+	read := func(path string) {
+		file := os.Stdin
+		if path != "-" {
+			var err error
+			if file, err = os.Open(path); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				mu.Lock()
+				failed = true
+				mu.Unlock()
+				return
+			}
+			defer file.Close()
+		}
+
+		// stdin is never followed, EOF means it was closed
+		if err := readLines(file, follow && file != os.Stdin, lines); err != nil {
+			fmt.Fprintf(os.Stderr, "error reading %s: %v\n", path, err)
+		}
+	}
+
+	if !follow {
+		for _, path := range files {
+			read(path)
+		}
+		return failed
+	}
+
+	var wg sync.WaitGroup
+	for _, path := range files {
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			read(path)
+		}(path)
+	}
+	wg.Wait()
+	return failed
+}
+
+// readLines sends the lines of f to lines, without the trailing
+// newline. If follow is set, it keeps waiting for new lines at EOF,
+// like `tail -f`, and starts again from the beginning if the file is
+// truncated.
+func readLines(f *os.File, follow bool, lines chan<- []byte) error {
+	buf := bufio.NewReader(f)
+	var partial []byte
+
+	for {
+		line, err := buf.ReadBytes('\n')
+		partial = append(partial, line...)
+
+		if err == nil {
+			lines <- bytes.TrimRight(partial, "\r\n")
+			partial = nil
+			continue
+		}
+
+		if err != io.EOF {
+			return err
+		}
+
+		if !follow {
+			if len(partial) > 0 {
+				lines <- partial
+			}
+			return nil
+		}
+
+		time.Sleep(followInterval)
+
+		if truncated(f) {
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			buf.Reset(f)
+			partial = nil
+		}
+	}
+}
+
+// truncated reports whether f is now smaller than the current read
+// offset.
+func truncated(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	offset, err := f.Seek(0, io.SeekCurrent)
+	return err == nil && info.Size() < offset
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"container/heap"
+	"container/list"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/theplant/appkit/log"
+)
+
+// maxPendingRequests is the number of requests waiting for their
+// summary line above which the oldest is printed as incomplete.
+const maxPendingRequests = 10000
+
+// grouper prints the lines of each request together, once the
+// request's summary line (logged by `server.LogRequest`, with
+// `request_us`) is read, or once no line of the request was read for
+// idleTimeout. Lines without `req_id` are printed immediately.
+type grouper struct {
+	out    io.Writer
+	colors bool
+
+	// keep filters lines after grouping them, so that the summary
+	// line is used for the header even if it's filtered out
+	keep func(kvs) bool
+
+	// slowRequest and slowQuery are the durations above which requests
+	// and queries are highlighted
+	slowRequest time.Duration
+	slowQuery   time.Duration
+
+	// idleTimeout is how long to wait for more lines of a request,
+	// before printing it as incomplete
+	idleTimeout time.Duration
+
+	now func() time.Time
+
+	// pending are the requests waiting for their summary line, by
+	// req_id, and in the order of their first line
+	pending map[string]*requestGroup
+	order   *list.List
+
+	// requests and queries are the top slowest requests and queries,
+	// listed by close
+	requests *slowest
+	queries  *slowest
+}
+
+type requestGroup struct {
+	id       string
+	lines    []kvs
+	lastSeen time.Time
+
+	// elem is the group's element in grouper.order
+	elem *list.Element
+}
+
+// timing is a request or query, for the slowest requests and queries
+// summary.
+type timing struct {
+	duration time.Duration
+	reqID    string
+	desc     string
+
+	// seq orders timings of the same duration by when they were read
+	seq int
+}
+
+// slowest keeps the top slowest timings added to it, in a min-heap of
+// at most top timings, so that following logs doesn't keep every
+// request and query.
+type slowest struct {
+	top     int
+	seq     int
+	timings timingHeap
+}
+
+func (s *slowest) add(t timing) {
+	if s.top <= 0 {
+		return
+	}
+
+	s.seq++
+	t.seq = s.seq
+
+	if len(s.timings) < s.top {
+		heap.Push(&s.timings, t)
+	} else if s.timings.less(s.timings[0], t) {
+		s.timings[0] = t
+		heap.Fix(&s.timings, 0)
+	}
+}
+
+// sorted returns the timings, slowest first.
+func (s *slowest) sorted() []timing {
+	timings := append([]timing{}, s.timings...)
+	sort.Slice(timings, func(i, j int) bool {
+		return s.timings.less(timings[j], timings[i])
+	})
+	return timings
+}
+
+// timingHeap is a min-heap of timings, where the fastest, and then
+// the latest read, is the first.
+type timingHeap []timing
+
+func (h timingHeap) less(a, b timing) bool {
+	if a.duration != b.duration {
+		return a.duration < b.duration
+	}
+	return a.seq > b.seq
+}
+
+func (h timingHeap) Len() int            { return len(h) }
+func (h timingHeap) Less(i, j int) bool  { return h.less(h[i], h[j]) }
+func (h timingHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *timingHeap) Push(x interface{}) { *h = append(*h, x.(timing)) }
+
+func (h *timingHeap) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return t
+}
+
+func newGrouper(out io.Writer, keep func(kvs) bool, slowRequest, slowQuery, idleTimeout time.Duration, top int) *grouper {
+	colors := false
+	if f, ok := out.(*os.File); ok {
+		colors = log.UseColors(f)
+	}
+
+	return &grouper{
+		out:         out,
+		colors:      colors,
+		keep:        keep,
+		slowRequest: slowRequest,
+		slowQuery:   slowQuery,
+		idleTimeout: idleTimeout,
+		now:         time.Now,
+		pending:     map[string]*requestGroup{},
+		order:       list.New(),
+		requests:    &slowest{top: top},
+		queries:     &slowest{top: top},
+	}
+}
+
+func (g *grouper) add(data kvs) {
+	id, ok := data.get("req_id")
+	if !ok || id == "" {
+		if g.keep(data) {
+			fmt.Fprint(g.out, log.PrettyFormat(data.keyvals()...))
+		}
+		return
+	}
+
+	group, ok := g.pending[id]
+	if !ok {
+		group = &requestGroup{id: id}
+		group.elem = g.order.PushBack(group)
+		g.pending[id] = group
+	}
+	group.lines = append(group.lines, data)
+	group.lastSeen = g.now()
+
+	if _, ok := data.get("request_us"); ok {
+		g.print(group, data)
+		g.remove(group)
+	}
+
+	for g.order.Len() > maxPendingRequests {
+		oldest := g.order.Front().Value.(*requestGroup)
+		g.print(oldest, nil)
+		g.remove(oldest)
+	}
+}
+
+// flushIdle prints the requests without new lines for idleTimeout as
+// incomplete, eg. when following logs of requests that never finish.
+func (g *grouper) flushIdle() {
+	if g.idleTimeout <= 0 {
+		return
+	}
+
+	now := g.now()
+	for e := g.order.Front(); e != nil; {
+		group := e.Value.(*requestGroup)
+		e = e.Next()
+		if now.Sub(group.lastSeen) >= g.idleTimeout {
+			g.print(group, nil)
+			g.remove(group)
+		}
+	}
+}
+
+func (g *grouper) remove(group *requestGroup) {
+	delete(g.pending, group.id)
+	g.order.Remove(group.elem)
+}
+
+// print prints the lines of group that pass the filter below a header
+// made from the request's summary line, or a plain header if summary
+// is nil. Nothing is printed if no line passes the filter.
+func (g *grouper) print(group *requestGroup, summary kvs) {
+	var kept []kvs
+	for _, line := range group.lines {
+		if g.keep(line) {
+			kept = append(kept, line)
+		}
+	}
+	if len(kept) == 0 {
+		return
+	}
+
+	if summary != nil {
+		method, _ := summary.get("method")
+		path, _ := summary.get("path")
+		status, _ := summary.get("status")
+		duration := micros(summary, "request_us")
+
+		durColor := "36"
+		if g.slowRequest > 0 && duration >= g.slowRequest {
+			durColor = "31;1"
+		}
+		fmt.Fprintf(g.out, "%s %s %s\n",
+			g.color("1", method+" "+path+" -> "+status), g.color(durColor, duration), g.color("90", "req_id="+group.id))
+
+		g.requests.add(timing{duration: duration, reqID: group.id, desc: method + " " + path + " -> " + status})
+	} else {
+		fmt.Fprintf(g.out, "%s %s\n", g.color("1", "(incomplete request)"), g.color("90", "req_id="+group.id))
+	}
+
+	for _, line := range kept {
+		if _, ok := line.get("request_us"); ok {
+			continue
+		}
+
+		marker := "    "
+		if query, ok := line.get("query"); ok {
+			duration := micros(line, "query_us")
+			if g.slowQuery > 0 && duration >= g.slowQuery {
+				marker = g.color("31;1", "  ! ")
+			}
+			g.queries.add(timing{duration: duration, reqID: group.id, desc: query})
+		}
+
+		fmt.Fprint(g.out, indent(log.PrettyFormat(without(line, "req_id").keyvals()...), marker, "    "))
+	}
+}
+
+// close prints requests still waiting for their summary line, and the
+// slowest requests and queries.
+func (g *grouper) close() {
+	for e := g.order.Front(); e != nil; e = e.Next() {
+		g.print(e.Value.(*requestGroup), nil)
+	}
+	g.pending = map[string]*requestGroup{}
+	g.order.Init()
+
+	g.printSlowest("requests", g.requests)
+	g.printSlowest("queries", g.queries)
+}
+
+func (g *grouper) printSlowest(what string, s *slowest) {
+	timings := s.sorted()
+	if len(timings) == 0 {
+		return
+	}
+
+	fmt.Fprintf(g.out, "\n%s\n", g.color("1", "Slowest "+what+":"))
+	for _, t := range timings {
+		fmt.Fprintf(g.out, "  %s %s %s\n", g.color("31", fmt.Sprintf("%10v", t.duration)), g.color("90", "req_id="+t.reqID), oneLine(t.desc))
+	}
+}
+
+// color wraps s in the ANSI color code, if printing colors.
+func (g *grouper) color(code string, s interface{}) string {
+	if !g.colors {
+		return fmt.Sprint(s)
+	}
+	return fmt.Sprintf("\033[%sm%v\033[0m", code, s)
+}
+
+// micros returns the duration in microseconds at key, 0 if it isn't a
+// number.
+func micros(data kvs, key string) time.Duration {
+	val, _ := data.get(key)
+	us, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(us * float64(time.Microsecond))
+}
+
+// without returns data without key.
+func without(data kvs, key string) kvs {
+	r := make(kvs, 0, len(data))
+	for _, d := range data {
+		if d.key != key {
+			r = append(r, d)
+		}
+	}
+	return r
+}
+
+// indent prefixes the first line of s with first, and other
+// non-empty lines with prefix.
+func indent(s, first, prefix string) string {
+	lines := strings.SplitAfter(s, "\n")
+	for i, line := range lines {
+		switch {
+		case i == 0:
+			lines[i] = first + line
+		case strings.TrimSpace(line) != "":
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// oneLine collapses whitespace in s, for multi-line SQL queries.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
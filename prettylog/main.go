@@ -1,52 +1,127 @@
+// Command prettylog prints logfmt or JSON logs in a human friendly
+// format, read from files or stdin:
+//
+//	prettylog [flags] [file ...]
+//
+// Lines that aren't structured logs are printed unchanged, unless
+// lines are filtered.
+//
+// With -group, the lines of each request (by `req_id`) are printed
+// together once the request is finished, below a header with the
+// request's method, path, status and duration. Requests without a
+// summary line are printed after -group-timeout without new lines.
 package main
 
 import (
-	"bufio"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/kr/logfmt"
 	"github.com/theplant/appkit/log"
 )
 
-type kv struct {
-	key string
-	val interface{}
-}
+func main() {
+	var (
+		f      filter
+		level  = flag.String("level", "", "only print lines at `level` or above (trace, debug, info, warn, error, crit, fatal)")
+		follow = flag.Bool("f", false, "follow files as they grow, like tail -f")
 
-type kvs []*kv
+		group       = flag.Bool("group", false, "print the lines of each request together, below the request's summary")
+		slowRequest = flag.Duration("slow-request", 500*time.Millisecond, "with -group, highlight requests slower than `duration`")
+		slowQuery   = flag.Duration("slow-query", 100*time.Millisecond, "with -group, highlight SQL queries slower than `duration`")
+		top         = flag.Int("top", 5, "with -group, list the `n` slowest requests and queries at the end (or on interrupt)")
+		groupIdle   = flag.Duration("group-timeout", 30*time.Second, "with -group, print requests without summary line after `duration` without new lines")
+	)
+	flag.StringVar(&f.reqID, "req-id", "", "only print lines of the request with `id`")
+	flag.Var(&f.matches, "match", "only print lines matching `key=value` (or key!=value), can be repeated")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: prettylog [flags] [file ...]\n\nReads stdin if no file (or -) is given.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
 
-func (k *kvs) HandleLogfmt(key, val []byte) error {
-	kv := &kv{
-		key: string(key),
-		val: string(val),
+	if *level != "" {
+		lvl, err := log.ParseLevel(*level)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		f.minLevel = &lvl
 	}
-	*k = append(*k, kv)
-	return nil
-}
 
-func main() {
-	buf := bufio.NewReader(os.Stdin)
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
 
-	var data kvs
+	lines := make(chan []byte)
+	failed := make(chan bool, 1)
+	go func() {
+		failed <- readFiles(files, *follow, lines)
+	}()
 
-	for {
-		line, err := buf.ReadBytes('\n')
+	var g *grouper
+	var tick <-chan time.Time
+	interrupted := make(chan os.Signal, 1)
+	if *group {
+		g = newGrouper(os.Stdout, f.keep, *slowRequest, *slowQuery, *groupIdle, *top)
 
-		data = make(kvs, 0)
-		if err := logfmt.Unmarshal(line, &data); err == nil {
-			r := []interface{}{}
-			for _, d := range data {
-				r = append(r, d.key, d.val)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		tick = ticker.C
+
+		// print the slowest requests and queries when interrupted, eg.
+		// when following files
+		signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	}
+
+loop:
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				break loop
 			}
-			fmt.Print(log.PrettyFormat(r...))
-		} else {
-			fmt.Println(string(line))
-			fmt.Println("error parsing log output", err)
+			printLine(f, g, line)
+		case <-tick:
+			g.flushIdle()
+		case <-interrupted:
+			g.close()
+			os.Exit(130)
 		}
-		// break after, to not miss the last line before EOF
-		if err != nil {
-			break
+	}
+
+	if g != nil {
+		g.close()
+	}
+
+	if <-failed {
+		os.Exit(1)
+	}
+}
+
+// printLine prints a line if it passes f, via g if grouping.
+func printLine(f filter, g *grouper, line []byte) {
+	data, ok := parseLine(line)
+	if !ok {
+		// filters can't apply to unstructured lines
+		if !f.active() {
+			fmt.Println(string(line))
 		}
+		return
+	}
+
+	// the grouper filters lines once grouped
+	if g != nil {
+		g.add(data)
+		return
+	}
+
+	if !f.keep(data) {
+		return
 	}
+	fmt.Print(log.PrettyFormat(data.keyvals()...))
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/kr/logfmt"
+)
+
+type kv struct {
+	key string
+	val interface{}
+}
+
+type kvs []*kv
+
+func (k *kvs) HandleLogfmt(key, val []byte) error {
+	kv := &kv{
+		key: string(key),
+		val: string(val),
+	}
+	*k = append(*k, kv)
+	return nil
+}
+
+// get returns the (last) value of key as a string.
+func (k kvs) get(key string) (string, bool) {
+	var val string
+	var found bool
+	for _, d := range k {
+		if d.key == key {
+			val, found = fmt.Sprint(d.val), true
+		}
+	}
+	return val, found
+}
+
+func (k kvs) keyvals() []interface{} {
+	r := []interface{}{}
+	for _, d := range k {
+		r = append(r, d.key, d.val)
+	}
+	return r
+}
+
+// parseLine parses a JSON or logfmt log line, and reports whether the
+// line is a structured log line at all.
+func parseLine(line []byte) (kvs, bool) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return nil, false
+	}
+
+	if line[0] == '{' {
+		data, err := parseJSON(line)
+		return data, err == nil
+	}
+
+	return parseLogfmt(line)
+}
+
+// parseLogfmt parses a logfmt line. kr/logfmt accepts almost anything
+// (every word is a key without value), so lines without any `key=value`
+// pair are not considered logfmt.
+func parseLogfmt(line []byte) (kvs, bool) {
+	data := make(kvs, 0)
+	hasValue := false
+	err := logfmt.Unmarshal(line, logfmt.HandlerFunc(func(key, val []byte) error {
+		if val != nil {
+			hasValue = true
+		}
+		return data.HandleLogfmt(key, val)
+	}))
+	if err != nil || !hasValue {
+		return nil, false
+	}
+	return data, true
+}
+
+// parseJSON parses a JSON object line, keeping the order of its keys.
+// Nested objects and arrays are kept as JSON.
+func parseJSON(line []byte) (kvs, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil, fmt.Errorf("not a JSON object")
+	}
+
+	data := make(kvs, 0)
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := t.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid JSON key %v", t)
+		}
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+
+		var val interface{} = strings.TrimSpace(string(raw))
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			val = s
+		}
+		data = append(data, &kv{key: key, val: val})
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theplant/appkit/log"
+)
+
+func TestParseLine(t *testing.T) {
+	cases := []struct {
+		line     string
+		keyvals  []interface{}
+		expectOK bool
+	}{
+		{`level=info msg="hello world" n=1`, []interface{}{"level", "info", "msg", "hello world", "n", "1"}, true},
+		{`{"level":"warn","msg":"slow","n":1.5,"user":{"id":1}}`, []interface{}{"level", "warn", "msg", "slow", "n", "1.5", "user", `{"id":1}`}, true},
+		{`Starting server on :8080`, nil, false},
+		{`{not json`, nil, false},
+		{``, nil, false},
+	}
+
+	for _, c := range cases {
+		data, ok := parseLine([]byte(c.line))
+		if ok != c.expectOK {
+			t.Errorf("%q: got ok %v, want %v", c.line, ok, c.expectOK)
+			continue
+		}
+		if ok && !reflect.DeepEqual(data.keyvals(), c.keyvals) {
+			t.Errorf("%q: got %v, want %v", c.line, data.keyvals(), c.keyvals)
+		}
+	}
+}
+
+func TestReadFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prettylog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var files []string
+	for _, name := range []string{"a", "b", "c"} {
+		path := filepath.Join(dir, name+".log")
+		content := strings.Repeat("msg="+name+"\n", 100)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+	files = append(files, filepath.Join(dir, "missing.log"))
+
+	lines := make(chan []byte)
+	failed := make(chan bool, 1)
+	go func() {
+		failed <- readFiles(files, false, lines)
+	}()
+
+	var got []string
+	for line := range lines {
+		if n := len(got); n == 0 || got[n-1] != string(line) {
+			got = append(got, string(line))
+		}
+	}
+
+	if want := []string{"msg=a", "msg=b", "msg=c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got lines %v, want files read in order %v", got, want)
+	}
+	if !<-failed {
+		t.Errorf("expected the missing file to fail")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	warn := log.LevelWarn
+	f := filter{minLevel: &warn, reqID: "abc"}
+	if err := f.matches.Set("context!=gorm"); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]bool{
+		`level=error req_id=abc msg=x`:              true,
+		`level=info req_id=abc msg=x`:               false,
+		`level=error req_id=def msg=x`:              false,
+		`level=error req_id=abc context=gorm msg=x`: false,
+		`req_id=abc msg="no level"`:                 true,
+	}
+
+	for line, expected := range cases {
+		data, _ := parseLine([]byte(line))
+		if got := f.keep(data); got != expected {
+			t.Errorf("%q: got %v, want %v", line, got, expected)
+		}
+	}
+}
+
+func TestGrouper(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	g := newGrouper(out, filter{}.keep, 500*time.Millisecond, 100*time.Millisecond, 0, 1)
+
+	for _, line := range []string{
+		`level=debug req_id=a msg="GET /orders"`,
+		`level=info msg="no request"`,
+		`level=debug req_id=b msg="GET /users"`,
+		`level=debug req_id=a context=gorm query_us=150000 query="SELECT * FROM orders"`,
+		`level=info req_id=a context=http method=GET path=/orders status=200 request_us=600000 msg="GET /orders -> 200 OK"`,
+	} {
+		data, _ := parseLine([]byte(line))
+		g.add(data)
+	}
+	g.close()
+
+	got := stripColors(out.String())
+	expected := `no request
+GET /orders -> 200 600ms req_id=a
+    GET /orders
+  ! context=gorm query_us=150000
+                SELECT * FROM orders
+(incomplete request) req_id=b
+    GET /users
+
+Slowest requests:
+       600ms req_id=a GET /orders -> 200
+
+Slowest queries:
+       150ms req_id=a SELECT * FROM orders
+`
+
+	for _, line := range strings.Split(expected, "\n") {
+		if !strings.Contains(got, line) {
+			t.Errorf("expected output to contain %q, got:\n%s", line, got)
+		}
+	}
+	if strings.Index(got, "req_id=b") < strings.Index(got, "req_id=a") {
+		t.Errorf("expected request a before b, got:\n%s", got)
+	}
+}
+
+func TestGrouperLevelFilter(t *testing.T) {
+	warn := log.LevelWarn
+	out := bytes.NewBuffer(nil)
+	g := newGrouper(out, filter{minLevel: &warn}.keep, 0, 0, 0, 5)
+
+	for _, line := range []string{
+		`level=info req_id=a msg="GET /orders"`,
+		`level=warn req_id=a msg="slow payment"`,
+		`level=info req_id=a method=GET path=/orders status=200 request_us=1000 msg="GET /orders -> 200 OK"`,
+		`level=info req_id=b method=GET path=/users status=200 request_us=2000 msg="GET /users -> 200 OK"`,
+	} {
+		data, _ := parseLine([]byte(line))
+		g.add(data)
+	}
+	g.close()
+
+	got := stripColors(out.String())
+	if !strings.Contains(got, "GET /orders -> 200 1ms req_id=a") {
+		t.Errorf("expected the header of request a from its filtered out summary, got:\n%s", got)
+	}
+	for _, unexpected := range []string{"incomplete", "req_id=b", `msg="GET /orders"`} {
+		if strings.Contains(got, unexpected) {
+			t.Errorf("unexpected %q in output:\n%s", unexpected, got)
+		}
+	}
+}
+
+func TestGrouperIdle(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	g := newGrouper(out, filter{}.keep, 0, 0, time.Minute, 5)
+
+	now := time.Now()
+	g.now = func() time.Time { return now }
+
+	for _, line := range []string{`req_id=a msg=started`, `req_id=b msg=started`} {
+		data, _ := parseLine([]byte(line))
+		g.add(data)
+		now = now.Add(30 * time.Second)
+	}
+
+	g.flushIdle()
+	if got := stripColors(out.String()); !strings.Contains(got, "(incomplete request) req_id=a") || strings.Contains(got, "req_id=b") {
+		t.Errorf("expected only request a to be flushed, got:\n%s", got)
+	}
+	if len(g.pending) != 1 {
+		t.Errorf("got %d pending requests, want 1", len(g.pending))
+	}
+}
+
+func TestSlowest(t *testing.T) {
+	s := &slowest{top: 3}
+	for i, ms := range []int{5, 1, 9, 5, 7, 2, 9} {
+		s.add(timing{duration: time.Duration(ms) * time.Millisecond, reqID: fmt.Sprint(i)})
+	}
+
+	if len(s.timings) != 3 {
+		t.Errorf("kept %d timings, want 3", len(s.timings))
+	}
+
+	var got []string
+	for _, tm := range s.sorted() {
+		got = append(got, tm.reqID)
+	}
+	// ties are listed in the order they were read
+	if strings.Join(got, ",") != "2,6,4" {
+		t.Errorf("got slowest %v, want [2 6 4]", got)
+	}
+
+	none := &slowest{}
+	none.add(timing{duration: time.Second})
+	if len(none.timings) != 0 {
+		t.Errorf("kept %d timings with top 0, want none", len(none.timings))
+	}
+}
+
+var colors = regexp.MustCompile("\033\\[[0-9;]*m")
+
+func stripColors(s string) string {
+	return colors.ReplaceAllString(s, "")
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/theplant/appkit/kerrs"
+	"github.com/theplant/appkit/log"
+)
+
+// Problem is a JSON problem details body (RFC 7807) describing an
+// error.
+type Problem struct {
+	Type   string          `json:"type"`
+	Title  string          `json:"title"`
+	Status int             `json:"status"`
+	Code   kerrs.ErrorCode `json:"code"`
+	Detail string          `json:"detail,omitempty"`
+
+	// Errors are validation errors of fields
+	Errors []kerrs.FieldError `json:"errors,omitempty"`
+}
+
+// WriteError responds to a request with the HTTP status of err's
+// `kerrs.Code`, and a JSON Problem body with the public parts of the
+// error: its `kerrs.PublicMessage` as `detail`, and its
+// `kerrs.Details` as `errors`. The error's message is never included,
+// to not leak internal details.
+//
+// Server errors (5xx) are logged with the request's logger.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	code := kerrs.Code(err)
+	status := code.HTTPStatus()
+
+	problem := Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   code,
+		Detail: kerrs.PublicMessage(err),
+		Errors: kerrs.Details(err),
+	}
+
+	if status >= http.StatusInternalServerError {
+		log.ForceContext(r.Context()).WithError(err).Log()
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem)
+}
@@ -0,0 +1,65 @@
+package server_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/theplant/appkit/kerrs"
+	"github.com/theplant/appkit/log"
+	"github.com/theplant/appkit/server"
+)
+
+func TestWriteError(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		body   string
+	}{
+		{
+			kerrs.Wrapv(kerrs.Newc(kerrs.NotFound, "order not found", "order_code", "A1"), "loading order"),
+			http.StatusNotFound,
+			`{"type":"about:blank","title":"Not Found","status":404,"code":"not_found"}`,
+		},
+		{
+			kerrs.WithPublic(kerrs.Wrapc(errors.New("duplicate key"), kerrs.Conflict, "saving order"), "Order already exists"),
+			http.StatusConflict,
+			`{"type":"about:blank","title":"Conflict","status":409,"code":"conflict","detail":"Order already exists"}`,
+		},
+		{
+			kerrs.Wrapv(kerrs.Invalid("Invalid address", kerrs.FieldError{Field: "zip", Message: "must be 5 digits"}), "saving address"),
+			http.StatusBadRequest,
+			`{"type":"about:blank","title":"Bad Request","status":400,"code":"invalid_argument","detail":"Invalid address","errors":[{"field":"zip","message":"must be 5 digits"}]}`,
+		},
+		{
+			// internal messages of client errors aren't leaked either
+			kerrs.Wrapc(errors.New("duplicate key value violates unique constraint"), kerrs.Conflict, "saving order"),
+			http.StatusConflict,
+			`{"type":"about:blank","title":"Conflict","status":409,"code":"conflict"}`,
+		},
+		{
+			errors.New("connection refused"),
+			http.StatusInternalServerError,
+			`{"type":"about:blank","title":"Internal Server Error","status":500,"code":"unknown"}`,
+		},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		r = r.WithContext(log.Context(r.Context(), log.NewNopLogger()))
+
+		server.WriteError(w, r, c.err)
+
+		if w.Code != c.status {
+			t.Errorf("%v: got status %d, want %d", c.err, w.Code, c.status)
+		}
+		if got := w.Header().Get("Content-Type"); got != "application/problem+json" {
+			t.Errorf("%v: got content type %q", c.err, got)
+		}
+		if got := w.Body.String(); got != c.body+"\n" {
+			t.Errorf("%v: got body\n%s\nwant\n%s", c.err, got, c.body)
+		}
+	}
+}
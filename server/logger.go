@@ -56,7 +56,7 @@ func LogRequest(h http.Handler) http.Handler {
 			// Will absorb panics in earlier middleware
 			if err := recover(); err != nil {
 				stack := stack(7)
-				httprequest, _ := httputil.DumpRequest(r, false)
+				httprequest, _ := httputil.DumpRequest(redactRequest(r), false)
 				l = l.With(
 					"err", err,
 					"request", string(httprequest),
@@ -81,6 +81,14 @@ func LogRequest(h http.Handler) http.Handler {
 	})
 }
 
+// redactRequest returns a shallow copy of r with sensitive headers
+// (eg. `Authorization` and `Cookie`) redacted, for dumping into logs.
+func redactRequest(r *http.Request) *http.Request {
+	redacted := r.WithContext(r.Context())
+	redacted.Header = log.DefaultRedactor.RedactHeader(r.Header)
+	return redacted
+}
+
 // Adapted from gin-gonic/gin/context.go and gin-gonic/gin/recovery.go
 
 var (
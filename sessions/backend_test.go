@@ -0,0 +1,33 @@
+package sessions_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/theplant/appkit/sessions"
+	"github.com/theplant/appkit/sessions/sessionstest"
+)
+
+func TestMemoryBackend(t *testing.T) {
+	sessionstest.TestBackend(t, sessions.NewMemoryBackend())
+}
+
+func TestFilesystemBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	b, err := sessions.NewFilesystemBackend(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionstest.TestBackend(t, b)
+
+	if _, _, err := b.Load(context.Background(), "../secret"); err == nil {
+		t.Errorf("Load accepted a session id escaping the directory")
+	}
+}
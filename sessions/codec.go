@@ -0,0 +1,97 @@
+package sessions
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is returned by `GetAs` when the session has no value
+// for the key, as is so that it matches with `errors.Is` and
+// `errors.Cause`.
+var ErrNotFound = errors.New("session value not found")
+
+// Codec encodes values stored by `PutAs`.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	// JSON is a Codec using `encoding/json`.
+	JSON Codec = jsonCodec{}
+
+	// Gob is a Codec using `encoding/gob`.
+	Gob Codec = gobCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	if err := gob.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// PutAs encodes value with codec and adds it for key into the
+// session, to be read by `GetAs` with the same codec.
+func PutAs[T any](ctx context.Context, key string, value T, codec Codec) error {
+	data, err := codec.Marshal(value)
+	if err != nil {
+		return errors.Wrapf(err, "cannot encode session key %v", key)
+	}
+
+	return put(ctx, key, string(data))
+}
+
+// GetAs retrieves the value of the given key stored by `PutAs`, and
+// decodes it with codec. It returns ErrNotFound if the session has no
+// value for key.
+func GetAs[T any](ctx context.Context, key string, codec Codec) (T, error) {
+	var value T
+
+	s, err := getSession(ctx)
+	if err != nil {
+		return value, err
+	}
+
+	session, err := s.get()
+	if err != nil {
+		return value, errors.Wrapf(err, "cannot get key %v from session", key)
+	}
+
+	raw, ok := session.Values[key]
+	if !ok {
+		return value, ErrNotFound
+	}
+
+	data, ok := raw.(string)
+	if !ok {
+		return value, errors.Errorf("the value of key %v is not encoded: %+v", key, raw)
+	}
+
+	if err := codec.Unmarshal([]byte(data), &value); err != nil {
+		return value, errors.Wrapf(err, "cannot decode session key %v", key)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,129 @@
+package sessions
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const sessionFilePrefix = "session_"
+
+// FilesystemBackend is a Backend that keeps each session in a file in
+// a directory, with the session's expiry time before its data.
+type FilesystemBackend struct {
+	dir string
+}
+
+// NewFilesystemBackend returns a FilesystemBackend storing sessions in
+// dir, that is created if it doesn't exist.
+func NewFilesystemBackend(dir string) (*FilesystemBackend, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrapf(err, "cannot create sessions directory %v", dir)
+	}
+	return &FilesystemBackend{dir: dir}, nil
+}
+
+func (b *FilesystemBackend) path(id string) (string, error) {
+	// IDs are generated by newSessionID, but check that they can't
+	// escape the directory anyway
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return "", fmt.Errorf("invalid session id %q", id)
+	}
+	return filepath.Join(b.dir, sessionFilePrefix+id), nil
+}
+
+// Load is part of Backend
+func (b *FilesystemBackend) Load(_ context.Context, id string) ([]byte, bool, error) {
+	path, err := b.path(id)
+	if err != nil {
+		return nil, false, err
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	data, expired := decodeSessionFile(content)
+	if expired {
+		return nil, false, nil
+	}
+	return data, true, nil
+}
+
+// Save is part of Backend, the session file is replaced atomically.
+func (b *FilesystemBackend) Save(_ context.Context, id string, data []byte, ttl time.Duration) error {
+	path, err := b.path(id)
+	if err != nil {
+		return err
+	}
+
+	content := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint64(content, uint64(time.Now().Add(ttl).Unix()))
+	content = append(content, data...)
+
+	tmp, err := ioutil.TempFile(b.dir, ".tmp_")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Delete is part of Backend
+func (b *FilesystemBackend) Delete(_ context.Context, id string) error {
+	path, err := b.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// DeleteExpired is part of Backend
+func (b *FilesystemBackend) DeleteExpired(_ context.Context) error {
+	paths, err := filepath.Glob(filepath.Join(b.dir, sessionFilePrefix+"*"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if _, expired := decodeSessionFile(content); expired {
+			os.Remove(path)
+		}
+	}
+	return nil
+}
+
+// decodeSessionFile returns the data of a session file, and whether
+// it has expired. Invalid files are expired.
+func decodeSessionFile(content []byte) (data []byte, expired bool) {
+	if len(content) < 8 {
+		return nil, true
+	}
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(content)), 0)
+	return content[8:], time.Now().After(expiresAt)
+}
@@ -0,0 +1,68 @@
+// Package gormstore keeps server-side sessions in a SQL database
+// table with Gorm, see `sessions.WithServerSession`.
+package gormstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Record is a session stored by Backend.
+type Record struct {
+	ID        string `gorm:"primary_key;size:64"`
+	Data      []byte
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+// TableName is the table of Record
+func (Record) TableName() string {
+	return "sessions"
+}
+
+// Backend is a `sessions.Backend` that keeps sessions in a SQL
+// database table, using a `gorm.DB` (eg. from `appkit/db.New`).
+type Backend struct {
+	db *gorm.DB
+}
+
+// New returns a Backend, and creates the `sessions` table if it
+// doesn't exist.
+func New(db *gorm.DB) (*Backend, error) {
+	if err := db.AutoMigrate(&Record{}).Error; err != nil {
+		return nil, err
+	}
+	return &Backend{db: db}, nil
+}
+
+// Load is part of sessions.Backend
+func (b *Backend) Load(_ context.Context, id string) ([]byte, bool, error) {
+	var record Record
+	err := b.db.Where("id = ? AND expires_at > ?", id, time.Now()).First(&record).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return record.Data, true, nil
+}
+
+// Save is part of sessions.Backend
+func (b *Backend) Save(_ context.Context, id string, data []byte, ttl time.Duration) error {
+	return b.db.Save(&Record{
+		ID:        id,
+		Data:      data,
+		ExpiresAt: time.Now().Add(ttl),
+	}).Error
+}
+
+// Delete is part of sessions.Backend
+func (b *Backend) Delete(_ context.Context, id string) error {
+	return b.db.Where("id = ?", id).Delete(&Record{}).Error
+}
+
+// DeleteExpired is part of sessions.Backend
+func (b *Backend) DeleteExpired(_ context.Context) error {
+	return b.db.Where("expires_at <= ?", time.Now()).Delete(&Record{}).Error
+}
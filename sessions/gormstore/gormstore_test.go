@@ -0,0 +1,24 @@
+package gormstore_test
+
+import (
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/theplant/appkit/sessions/gormstore"
+	"github.com/theplant/appkit/sessions/sessionstest"
+)
+
+func TestBackend(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	b, err := gormstore.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionstest.TestBackend(t, b)
+}
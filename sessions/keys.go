@@ -0,0 +1,100 @@
+package sessions
+
+import (
+	"encoding/base64"
+
+	"github.com/pkg/errors"
+)
+
+// CookieKey is a pair of base64 encoded keys, to sign and optionally
+// encrypt session cookies.
+//
+// The hash key must be at least 32 bytes, and the encryption key 16,
+// 24 or 32 bytes to select AES-128, AES-192 or AES-256. Generate them
+// with eg. `openssl rand -base64 32`.
+type CookieKey struct {
+	HashKey       string `required:"true"`
+	EncryptionKey string
+}
+
+const minHashKeyLength = 32
+
+// Validate checks that the configuration has a name, valid keys, and
+// valid cookie attributes.
+// `NewSessionMiddleware` returns this error, and `WithSession` panics
+// with it.
+func (c CookieStoreConfig) Validate() error {
+	if c.Name == "" {
+		return errors.New("session name must be present")
+	}
+
+	if err := c.validateCookie(); err != nil {
+		return err
+	}
+
+	if c.Key == "" && len(c.Keys) == 0 {
+		return errors.New("session Key or Keys must be present")
+	}
+
+	for i, k := range c.Keys {
+		hashKey, err := base64.StdEncoding.DecodeString(k.HashKey)
+		if err != nil {
+			return errors.Wrapf(err, "session Keys[%d].HashKey is not base64", i)
+		}
+		if len(hashKey) < minHashKeyLength {
+			return errors.Errorf("session Keys[%d].HashKey must be at least %d bytes, got %d", i, minHashKeyLength, len(hashKey))
+		}
+
+		if k.EncryptionKey == "" {
+			continue
+		}
+		encryptionKey, err := base64.StdEncoding.DecodeString(k.EncryptionKey)
+		if err != nil {
+			return errors.Wrapf(err, "session Keys[%d].EncryptionKey is not base64", i)
+		}
+		switch len(encryptionKey) {
+		case 16, 24, 32:
+		default:
+			return errors.Errorf("session Keys[%d].EncryptionKey must be 16, 24 or 32 bytes, got %d", i, len(encryptionKey))
+		}
+	}
+
+	// Key is used as is, only its encoding is checked, like before
+	// Keys was introduced
+	if c.Key != "" {
+		if _, err := base64.StdEncoding.DecodeString(c.Key); err != nil {
+			return errors.Wrap(err, "session Key is not base64")
+		}
+	}
+
+	return nil
+}
+
+// keyPairs returns the hash and encryption keys of the config, for
+// `securecookie.CodecsFromPairs`. The first pair encodes cookies, and
+// all of them decode cookies.
+//
+// `Key` is the last, and used as is instead of base64 decoded, to keep
+// decoding the cookies signed before `Keys` was introduced.
+func (c CookieStoreConfig) keyPairs() [][]byte {
+	pairs := [][]byte{}
+
+	for _, k := range c.Keys {
+		// Invalid keys are rejected by Validate, and are left nil to
+		// fail encoding otherwise.
+		hashKey, _ := base64.StdEncoding.DecodeString(k.HashKey)
+
+		var encryptionKey []byte
+		if k.EncryptionKey != "" {
+			encryptionKey, _ = base64.StdEncoding.DecodeString(k.EncryptionKey)
+		}
+
+		pairs = append(pairs, hashKey, encryptionKey)
+	}
+
+	if c.Key != "" {
+		pairs = append(pairs, []byte(c.Key), nil)
+	}
+
+	return pairs
+}
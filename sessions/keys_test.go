@@ -0,0 +1,107 @@
+package sessions
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const (
+	// 32 bytes keys, base64 encoded
+	testHashKey1       = "YJcKk5ZGcPxBFtj7HkdXfKtUS2FwZqLVwLB0cVnNxu8="
+	testHashKey2       = "Hh2MJy8xSK7FXo4qIyWbYdjzrjWqAw4qpHe0rEwbVJk="
+	testEncryptionKey1 = "3AVj9mCdjCWVbIqx4h2k7dbWRCajsp6P0UyyzzPDJcU="
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name   string
+		config CookieStoreConfig
+		err    string
+	}{
+		{"legacy key", CookieStoreConfig{Name: "s", Key: testConf.Key}, ""},
+		{"keys", CookieStoreConfig{Name: "s", Keys: []CookieKey{{testHashKey1, testEncryptionKey1}, {testHashKey2, ""}}}, ""},
+		{"no name", CookieStoreConfig{Key: testConf.Key}, "session name must be present"},
+		{"no key", CookieStoreConfig{Name: "s"}, "session Key or Keys must be present"},
+		{"legacy key not base64", CookieStoreConfig{Name: "s", Key: "not base64!"}, "session Key is not base64"},
+		{"short legacy key", CookieStoreConfig{Name: "s", Key: "c2VjcmV0"}, ""},
+		{"hash key too short", CookieStoreConfig{Name: "s", Keys: []CookieKey{{HashKey: "c2VjcmV0"}}}, "session Keys[0].HashKey must be at least 32 bytes, got 6"},
+		{"encryption key length", CookieStoreConfig{Name: "s", Keys: []CookieKey{{testHashKey1, ""}, {testHashKey2, "c2VjcmV0"}}}, "session Keys[1].EncryptionKey must be 16, 24 or 32 bytes, got 6"},
+		{"same site", CookieStoreConfig{Name: "s", Key: testConf.Key, SameSite: "strict"}, ""},
+		{"unknown same site", CookieStoreConfig{Name: "s", Key: testConf.Key, SameSite: "loose"}, `session SameSite must be Lax, Strict or None, got "loose"`},
+		{"same site none", CookieStoreConfig{Name: "s", Key: testConf.Key, SameSite: "None", NoSecure: true}, "session cookie with SameSite None must be Secure"},
+		{"partitioned", CookieStoreConfig{Name: "s", Key: testConf.Key, Partitioned: true, NoSecure: true}, "partitioned session cookie must be Secure"},
+		{"secure prefix", CookieStoreConfig{Name: "__Secure-s", Key: testConf.Key, NoSecure: true}, "session cookie __Secure-s must be Secure"},
+		{"host prefix", CookieStoreConfig{Name: "__Host-s", Key: testConf.Key, Path: "/"}, ""},
+		{"host prefix domain", CookieStoreConfig{Name: "__Host-s", Key: testConf.Key, Path: "/", Domain: "example.com"}, "session cookie __Host-s must not have a Domain"},
+		{"host prefix path", CookieStoreConfig{Name: "__Host-s", Key: testConf.Key, Path: "/app"}, `session cookie __Host-s must have Path "/"`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.config.Validate()
+			if c.err == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+			} else if err == nil || !strings.HasPrefix(err.Error(), c.err) {
+				t.Errorf("Validate() = %v, want %q", err, c.err)
+			}
+		})
+	}
+}
+
+func TestNewSessionMiddleware(t *testing.T) {
+	invalid := &CookieStoreConfig{Name: "s"}
+
+	if _, err := NewSessionMiddleware(invalid); err == nil {
+		t.Error("NewSessionMiddleware with an invalid config succeeded")
+	}
+	if _, err := NewServerSessionMiddleware(invalid, NewMemoryBackend()); err == nil {
+		t.Error("NewServerSessionMiddleware with an invalid config succeeded")
+	}
+	if _, err := NewSessionMiddleware(testConf); err != nil {
+		t.Errorf("NewSessionMiddleware: %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("WithSession with an invalid config didn't panic")
+		}
+	}()
+	WithSession(invalid)
+}
+
+func TestKeyRotation(t *testing.T) {
+	get := func(conf *CookieStoreConfig, cookies []*http.Cookie) string {
+		return testHandler(conf, func(w http.ResponseWriter, r *http.Request) {
+			v, _ := Get(r.Context(), "uid")
+			w.Write([]byte(v))
+		})(cookies).Body.String()
+	}
+
+	put := func(conf *CookieStoreConfig) []*http.Cookie {
+		return testHandler(conf, func(w http.ResponseWriter, r *http.Request) {
+			Put(r.Context(), "uid", "123")
+		})(nil).Result().Cookies()
+	}
+
+	legacy := &CookieStoreConfig{Name: "s", Key: testConf.Key}
+	old := &CookieStoreConfig{Name: "s", Keys: []CookieKey{{testHashKey1, testEncryptionKey1}}}
+	rotated := &CookieStoreConfig{Name: "s", Keys: []CookieKey{{testHashKey2, ""}, {testHashKey1, testEncryptionKey1}}, Key: testConf.Key}
+
+	if got := get(rotated, put(legacy)); got != "123" {
+		t.Errorf("cookie of the legacy key: got %q, want \"123\"", got)
+	}
+	if got := get(rotated, put(old)); got != "123" {
+		t.Errorf("cookie of the old key: got %q, want \"123\"", got)
+	}
+
+	cookies := put(rotated)
+	if got := get(old, cookies); got != "" {
+		t.Errorf("cookie of the new key decoded with the old key: got %q", got)
+	}
+	if got := get(&CookieStoreConfig{Name: "s", Keys: rotated.Keys[:1]}, cookies); got != "123" {
+		t.Errorf("cookie of the new key: got %q, want \"123\"", got)
+	}
+}
@@ -0,0 +1,168 @@
+package sessions
+
+import (
+	"context"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/pkg/errors"
+)
+
+// Keys of the values used by the sessions package itself.
+const (
+	createdAtKey  = "_created_at"
+	accessedAtKey = "_accessed_at"
+	userIDKey     = "_user_id"
+)
+
+// now is replaced in tests
+var now = time.Now
+
+// Regenerate gives the session a new ID, keeping its values, and
+// restarts its absolute timeout. Call it when the user logs in, to
+// protect against session fixation.
+func Regenerate(ctx context.Context) error {
+	s, err := getSession(ctx)
+	if err != nil {
+		return err
+	}
+
+	session, err := s.get()
+	if err != nil {
+		return errors.Wrap(err, "cannot regenerate session")
+	}
+
+	if err := s.regenerate(session); err != nil {
+		return errors.Wrap(err, "cannot regenerate session")
+	}
+
+	delete(session.Values, createdAtKey)
+	s.dirty = true
+	return nil
+}
+
+// Destroy deletes the values of the session, and the session itself
+// when the response is written. Call it when the user logs out.
+func Destroy(ctx context.Context) error {
+	s, err := getSession(ctx)
+	if err != nil {
+		return err
+	}
+
+	session, err := s.get()
+	if err != nil {
+		return errors.Wrap(err, "cannot destroy session")
+	}
+
+	clearValues(session)
+	session.Options.MaxAge = -1
+	s.dirty = true
+	return nil
+}
+
+// SetUserID records the ID of the user logged in with the session,
+// so that `LogoutEverywhere` can revoke it.
+func SetUserID(ctx context.Context, userID string) error {
+	return put(ctx, userIDKey, userID)
+}
+
+// LogoutEverywhere revokes all the sessions of the user recorded by
+// `SetUserID` before now, including the current one. It needs a
+// server-side session store, see `WithServerSession`.
+func LogoutEverywhere(ctx context.Context, userID string) error {
+	s, err := getSession(ctx)
+	if err != nil {
+		return err
+	}
+
+	store, ok := s.store.(*ServerStore)
+	if !ok {
+		return errors.New("cannot log out everywhere without a server-side session store")
+	}
+
+	return store.LogoutEverywhere(ctx, userID)
+}
+
+// check resets the session if it has timed out, or has been revoked
+// by `LogoutEverywhere`.
+func (s *session) check(session *sessions.Session) error {
+	if session.IsNew {
+		return nil
+	}
+
+	expired, err := s.expired(session, now())
+	if err != nil {
+		return err
+	}
+
+	if expired {
+		if err := s.regenerate(session); err != nil {
+			return err
+		}
+		clearValues(session)
+		s.dirty = true
+		return nil
+	}
+
+	// Save the access time, for the idle timeout
+	if s.config.IdleTimeout > 0 {
+		s.dirty = true
+	}
+
+	return nil
+}
+
+func (s *session) expired(session *sessions.Session, t time.Time) (bool, error) {
+	createdAt, _ := session.Values[createdAtKey].(int64)
+	accessedAt, _ := session.Values[accessedAtKey].(int64)
+
+	if s.config.AbsoluteTimeout > 0 && createdAt != 0 &&
+		t.Sub(time.Unix(0, createdAt)) > time.Duration(s.config.AbsoluteTimeout)*time.Second {
+		return true, nil
+	}
+
+	if s.config.IdleTimeout > 0 && accessedAt != 0 &&
+		t.Sub(time.Unix(0, accessedAt)) > time.Duration(s.config.IdleTimeout)*time.Second {
+		return true, nil
+	}
+
+	userID, _ := session.Values[userIDKey].(string)
+	store, ok := s.store.(*ServerStore)
+	if userID == "" || !ok {
+		return false, nil
+	}
+
+	revokedAt, err := store.revokedAt(s.r.Context(), userID)
+	if err != nil {
+		return false, errors.Wrapf(err, "cannot check revocation of user %v sessions", userID)
+	}
+
+	return !revokedAt.IsZero() && createdAt <= revokedAt.UnixNano(), nil
+}
+
+// touch records the creation and access times of the session before
+// it's saved.
+func (s *session) touch(session *sessions.Session) {
+	t := now().UnixNano()
+	if _, ok := session.Values[createdAtKey]; !ok {
+		session.Values[createdAtKey] = t
+	}
+	if s.config.IdleTimeout > 0 {
+		session.Values[accessedAtKey] = t
+	}
+}
+
+// regenerate deletes the session from a server-side store, so that it
+// is saved with a new ID.
+func (s *session) regenerate(session *sessions.Session) error {
+	if store, ok := s.store.(*ServerStore); ok {
+		return store.regenerate(s.r.Context(), session)
+	}
+	return nil
+}
+
+func clearValues(session *sessions.Session) {
+	for k := range session.Values {
+		delete(session.Values, k)
+	}
+}
@@ -0,0 +1,252 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testServerHandler returns a function to send requests with cookies
+// to h, behind `WithServerSession`.
+func testServerHandler(conf *CookieStoreConfig, backend Backend, h http.HandlerFunc) func(cookies []*http.Cookie) *httptest.ResponseRecorder {
+	handler := WithServerSession(conf, backend)(h)
+	return func(cookies []*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+}
+
+func TestRegenerate(t *testing.T) {
+	backend := NewMemoryBackend()
+
+	login := testServerHandler(testConf, backend, func(w http.ResponseWriter, r *http.Request) {
+		if err := Regenerate(r.Context()); err != nil {
+			t.Fatal(err)
+		}
+		Put(r.Context(), "uid", "123")
+	})
+
+	get := testServerHandler(testConf, backend, func(w http.ResponseWriter, r *http.Request) {
+		v, _ := Get(r.Context(), "uid")
+		w.Write([]byte(v))
+	})
+
+	put := testServerHandler(testConf, backend, func(w http.ResponseWriter, r *http.Request) {
+		Put(r.Context(), "cart", "1")
+	})
+
+	// A session fixed by an attacker before the login
+	fixed := put(nil).Result().Cookies()
+	loggedIn := login(fixed).Result().Cookies()
+
+	if got := get(loggedIn).Body.String(); got != "123" {
+		t.Errorf("Get(uid) = %q after login, want \"123\"", got)
+	}
+	if got := get(fixed).Body.String(); got != "" {
+		t.Errorf("Get(uid) = %q with the session before login, want \"\"", got)
+	}
+}
+
+func TestDestroy(t *testing.T) {
+	backend := NewMemoryBackend()
+
+	put := testServerHandler(testConf, backend, func(w http.ResponseWriter, r *http.Request) {
+		Put(r.Context(), "uid", "123")
+	})
+
+	logout := testServerHandler(testConf, backend, func(w http.ResponseWriter, r *http.Request) {
+		if err := Destroy(r.Context()); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	resp := logout(put(nil).Result().Cookies()).Result()
+
+	if len(backend.sessions) != 0 {
+		t.Errorf("got %d sessions in backend after Destroy, want 0", len(backend.sessions))
+	}
+	if cookies := resp.Cookies(); len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+		t.Errorf("Destroy didn't expire the cookie: %v", cookies)
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	defer func() { now = time.Now }()
+
+	cases := []struct {
+		name            string
+		idleTimeout     int
+		absoluteTimeout int
+		age             time.Duration
+		expired         bool
+	}{
+		{"recently accessed", 60, 0, 30 * time.Second, false},
+		{"idle", 60, 0, 2 * time.Minute, true},
+		{"recently created", 0, 3600, 30 * time.Minute, false},
+		{"too old", 0, 3600, 2 * time.Hour, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			conf := *testConf
+			conf.IdleTimeout = c.idleTimeout
+			conf.AbsoluteTimeout = c.absoluteTimeout
+
+			put := testHandler(&conf, func(w http.ResponseWriter, r *http.Request) {
+				Put(r.Context(), "uid", "123")
+			})
+
+			get := testHandler(&conf, func(w http.ResponseWriter, r *http.Request) {
+				v, _ := Get(r.Context(), "uid")
+				w.Write([]byte(v))
+			})
+
+			now = func() time.Time { return time.Now().Add(-c.age) }
+			cookies := put(nil).Result().Cookies()
+			now = time.Now
+
+			got := get(cookies).Body.String()
+			if expired := got == ""; expired != c.expired {
+				t.Errorf("expired = %v, want %v", expired, c.expired)
+			}
+		})
+	}
+}
+
+func TestLogoutEverywhere(t *testing.T) {
+	backend := NewMemoryBackend()
+
+	login := testServerHandler(testConf, backend, func(w http.ResponseWriter, r *http.Request) {
+		Regenerate(r.Context())
+		SetUserID(r.Context(), "alice")
+		Put(r.Context(), "uid", "alice")
+	})
+
+	get := testServerHandler(testConf, backend, func(w http.ResponseWriter, r *http.Request) {
+		v, _ := Get(r.Context(), "uid")
+		w.Write([]byte(v))
+	})
+
+	logoutEverywhere := testServerHandler(testConf, backend, func(w http.ResponseWriter, r *http.Request) {
+		if err := LogoutEverywhere(r.Context(), "alice"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	laptop := login(nil).Result().Cookies()
+	phone := login(nil).Result().Cookies()
+
+	logoutEverywhere(phone)
+
+	if got := get(laptop).Body.String(); got != "" {
+		t.Errorf("Get(uid) = %q on laptop, want \"\"", got)
+	}
+	if got := get(phone).Body.String(); got != "" {
+		t.Errorf("Get(uid) = %q on phone, want \"\"", got)
+	}
+
+	if got := get(login(nil).Result().Cookies()).Body.String(); got != "alice" {
+		t.Errorf("Get(uid) = %q after logging in again, want \"alice\"", got)
+	}
+}
+
+func TestLogoutEverywhereClock(t *testing.T) {
+	defer func() { now = time.Now }()
+	clock := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+
+	backend := NewMemoryBackend()
+
+	login := testServerHandler(testConf, backend, func(w http.ResponseWriter, r *http.Request) {
+		SetUserID(r.Context(), "alice")
+		Put(r.Context(), "uid", "alice")
+	})
+
+	get := testServerHandler(testConf, backend, func(w http.ResponseWriter, r *http.Request) {
+		v, _ := Get(r.Context(), "uid")
+		w.Write([]byte(v))
+	})
+
+	logoutEverywhere := testServerHandler(testConf, backend, func(w http.ResponseWriter, r *http.Request) {
+		LogoutEverywhere(r.Context(), "alice")
+	})
+
+	before := login(nil).Result().Cookies()
+	clock = clock.Add(time.Minute)
+	logoutEverywhere(before)
+	clock = clock.Add(time.Minute)
+	after := login(nil).Result().Cookies()
+
+	if got := get(before).Body.String(); got != "" {
+		t.Errorf("Get(uid) = %q for a session created before the logout, want \"\"", got)
+	}
+	if got := get(after).Body.String(); got != "alice" {
+		t.Errorf("Get(uid) = %q for a session created after the logout, want \"alice\"", got)
+	}
+}
+
+func TestLogoutEverywhereCookieStore(t *testing.T) {
+	do := testHandler(testConf, func(w http.ResponseWriter, r *http.Request) {
+		if err := LogoutEverywhere(context.Background(), "alice"); err == nil {
+			t.Errorf("LogoutEverywhere without session succeeded")
+		}
+		if err := LogoutEverywhere(r.Context(), "alice"); err == nil {
+			t.Errorf("LogoutEverywhere with a cookie store succeeded")
+		}
+	})
+	do(nil)
+}
+
+// revocationFailingBackend is a Backend that fails to load the
+// revocations of users.
+type revocationFailingBackend struct {
+	Backend
+	fail bool
+}
+
+func (b *revocationFailingBackend) Load(ctx context.Context, id string) ([]byte, bool, error) {
+	if b.fail && strings.HasPrefix(id, "user_") {
+		return nil, false, errors.New("backend unavailable")
+	}
+	return b.Backend.Load(ctx, id)
+}
+
+func TestRevocationCheckFailure(t *testing.T) {
+	backend := &revocationFailingBackend{Backend: NewMemoryBackend()}
+
+	login := testServerHandler(testConf, backend, func(w http.ResponseWriter, r *http.Request) {
+		SetUserID(r.Context(), "alice")
+		Put(r.Context(), "uid", "alice")
+	})
+
+	get := testServerHandler(testConf, backend, func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		for i := 0; i < 2; i++ {
+			if v, err := Get(ctx, "uid"); err == nil {
+				t.Errorf("Get(uid) = %q with a failing revocation check, want an error", v)
+			}
+		}
+		if err := Put(ctx, "a", "1"); err == nil {
+			t.Error("Put succeeded with a failing revocation check")
+		}
+		if err := Save(ctx); err != nil {
+			t.Errorf("Save after failed Put: %v", err)
+		}
+	})
+
+	cookies := login(nil).Result().Cookies()
+
+	backend.fail = true
+	if got := len(get(cookies).Result().Header["Set-Cookie"]); got != 0 {
+		t.Errorf("got %d Set-Cookie headers, want none", got)
+	}
+}
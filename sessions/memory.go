@@ -0,0 +1,71 @@
+package sessions
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryBackend is a Backend that keeps sessions in memory, for tests
+// and single-process apps. Sessions are lost when the process exits.
+type MemoryBackend struct {
+	mu       sync.Mutex
+	sessions map[string]memorySession
+}
+
+type memorySession struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+// NewMemoryBackend returns an empty MemoryBackend.
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{sessions: map[string]memorySession{}}
+}
+
+// Load is part of Backend
+func (b *MemoryBackend) Load(_ context.Context, id string) ([]byte, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.sessions[id]
+	if !ok || time.Now().After(s.expiresAt) {
+		return nil, false, nil
+	}
+	return s.data, true, nil
+}
+
+// Save is part of Backend
+func (b *MemoryBackend) Save(_ context.Context, id string, data []byte, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sessions[id] = memorySession{
+		data:      append([]byte(nil), data...),
+		expiresAt: time.Now().Add(ttl),
+	}
+	return nil
+}
+
+// Delete is part of Backend
+func (b *MemoryBackend) Delete(_ context.Context, id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.sessions, id)
+	return nil
+}
+
+// DeleteExpired is part of Backend
+func (b *MemoryBackend) DeleteExpired(_ context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	for id, s := range b.sessions {
+		if now.After(s.expiresAt) {
+			delete(b.sessions, id)
+		}
+	}
+	return nil
+}
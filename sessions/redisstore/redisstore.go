@@ -0,0 +1,49 @@
+// Package redisstore keeps server-side sessions in Redis, see
+// `sessions.WithServerSession`.
+package redisstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// Backend is a `sessions.Backend` that keeps sessions in Redis, that
+// expires them itself.
+type Backend struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// New returns a Backend storing sessions with keys prefixed by
+// prefix, eg. "session:".
+func New(client redis.Cmdable, prefix string) *Backend {
+	return &Backend{client: client, prefix: prefix}
+}
+
+// Load is part of sessions.Backend
+func (b *Backend) Load(_ context.Context, id string) ([]byte, bool, error) {
+	data, err := b.client.Get(b.prefix + id).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// Save is part of sessions.Backend
+func (b *Backend) Save(_ context.Context, id string, data []byte, ttl time.Duration) error {
+	return b.client.Set(b.prefix+id, data, ttl).Err()
+}
+
+// Delete is part of sessions.Backend
+func (b *Backend) Delete(_ context.Context, id string) error {
+	return b.client.Del(b.prefix + id).Err()
+}
+
+// DeleteExpired is part of sessions.Backend, Redis expires sessions itself.
+func (b *Backend) DeleteExpired(_ context.Context) error {
+	return nil
+}
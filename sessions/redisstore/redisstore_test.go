@@ -0,0 +1,39 @@
+package redisstore_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/theplant/appkit/sessions/redisstore"
+)
+
+func TestBackend(t *testing.T) {
+	addr := os.Getenv("APPKIT_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("APPKIT_TEST_REDIS_ADDR not set")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+
+	b := redisstore.New(client, "appkit_test_session:")
+
+	// Redis rejects negative expiry, so skip the expired session
+	// checks of sessionstest.TestBackend
+	ctx := context.Background()
+	if err := b.Save(ctx, "live", []byte("data"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if data, found, err := b.Load(ctx, "live"); err != nil || !found || string(data) != "data" {
+		t.Fatalf("Load(live) = %q, %v, %v, want \"data\", true, nil", data, found, err)
+	}
+	if err := b.Delete(ctx, "live"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, err := b.Load(ctx, "live"); err != nil || found {
+		t.Errorf("Load(live) = %v, %v after Delete, want false, nil", found, err)
+	}
+}
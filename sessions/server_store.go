@@ -0,0 +1,236 @@
+package sessions
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/gob"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/pkg/errors"
+	"github.com/theplant/appkit/log"
+)
+
+// DefaultTTL is how long sessions are kept in a Backend when the
+// cookie's MaxAge is 0 (ie. a browser session cookie).
+const DefaultTTL = 24 * time.Hour
+
+// Backend stores session values server-side, by session ID.
+// Implementations must be safe for concurrent use.
+type Backend interface {
+	// Load returns the data of a session, and false if the session
+	// doesn't exist or has expired.
+	Load(ctx context.Context, id string) (data []byte, found bool, err error)
+
+	// Save stores the data of a session, that expires after ttl.
+	Save(ctx context.Context, id string, data []byte, ttl time.Duration) error
+
+	// Delete removes a session.
+	Delete(ctx context.Context, id string) error
+
+	// DeleteExpired removes expired sessions, see StartCleanup.
+	// Backends that expire sessions themselves do nothing.
+	DeleteExpired(ctx context.Context) error
+}
+
+// ServerStore is a `gorilla/sessions.Store` that keeps session values
+// in a Backend, and only a signed session ID in the cookie, so that
+// sessions aren't limited by the cookie size, and can be revoked.
+type ServerStore struct {
+	Codecs  []securecookie.Codec
+	Options *sessions.Options
+
+	backend Backend
+}
+
+// NewServerStore initializes a ServerStore by `CookieStoreConfig`,
+// storing sessions in backend. `config.Keys` (and `config.Key`) are
+// used to sign the session ID cookie.
+func NewServerStore(config CookieStoreConfig, backend Backend) *ServerStore {
+	s := &ServerStore{
+		Codecs:  securecookie.CodecsFromPairs(config.keyPairs()...),
+		Options: cookieOptions(config),
+		backend: backend,
+	}
+
+	for _, codec := range s.Codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(s.Options.MaxAge)
+		}
+	}
+
+	return s
+}
+
+// Get is part of `gorilla/sessions.Store`, it returns a session
+// cached in the request's registry, or loads it from the backend.
+func (s *ServerStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New is part of `gorilla/sessions.Store`, it loads the session from
+// the backend, or returns a new session.
+func (s *ServerStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+
+	var id string
+	if err := securecookie.DecodeMulti(name, c.Value, &id, s.Codecs...); err != nil {
+		return session, err
+	}
+
+	data, found, err := s.backend.Load(r.Context(), id)
+	if err != nil {
+		return session, errors.Wrapf(err, "cannot load session %v", name)
+	}
+	if !found {
+		return session, nil
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&session.Values); err != nil {
+		return session, errors.Wrapf(err, "cannot decode session %v", name)
+	}
+
+	session.ID = id
+	session.IsNew = false
+	return session, nil
+}
+
+// Save is part of `gorilla/sessions.Store`, it saves the session
+// values to the backend, and the session ID to the cookie. Sessions
+// with a negative MaxAge are deleted.
+func (s *ServerStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := s.backend.Delete(r.Context(), session.ID); err != nil {
+				return errors.Wrapf(err, "cannot delete session %v", session.Name())
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	if session.ID == "" {
+		session.ID = newSessionID()
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := gob.NewEncoder(buf).Encode(session.Values); err != nil {
+		return errors.Wrapf(err, "cannot encode session %v", session.Name())
+	}
+
+	if err := s.backend.Save(r.Context(), session.ID, buf.Bytes(), sessionTTL(session.Options)); err != nil {
+		return errors.Wrapf(err, "cannot save session %v", session.Name())
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return errors.Wrapf(err, "cannot encode session %v cookie", session.Name())
+	}
+
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// LogoutEverywhere revokes all the sessions of the user recorded by
+// `SetUserID` before now. Sessions are checked when they are read.
+func (s *ServerStore) LogoutEverywhere(ctx context.Context, userID string) error {
+	// Stamped with the clock of the sessions' creation time, that it's
+	// compared to
+	revokedAt := strconv.FormatInt(now().UnixNano(), 10)
+
+	// Sessions live at most their TTL after their last save, so the
+	// revocation doesn't need to outlive it
+	if err := s.backend.Save(ctx, revocationID(userID), []byte(revokedAt), sessionTTL(s.Options)); err != nil {
+		return errors.Wrapf(err, "cannot revoke sessions of user %v", userID)
+	}
+	return nil
+}
+
+// revokedAt returns the time of the last `LogoutEverywhere` of the
+// user, or zero.
+func (s *ServerStore) revokedAt(ctx context.Context, userID string) (time.Time, error) {
+	data, found, err := s.backend.Load(ctx, revocationID(userID))
+	if err != nil || !found {
+		return time.Time{}, err
+	}
+
+	nanos, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, nanos), nil
+}
+
+// regenerate deletes the session from the backend, so that it's saved
+// with a new ID.
+func (s *ServerStore) regenerate(ctx context.Context, session *sessions.Session) error {
+	if session.ID == "" {
+		return nil
+	}
+	if err := s.backend.Delete(ctx, session.ID); err != nil {
+		return err
+	}
+	session.ID = ""
+	return nil
+}
+
+// revocationID is the backend ID of the revocation of the sessions of
+// a user, that is safe to use in file names and keys whatever the
+// user ID.
+func revocationID(userID string) string {
+	sum := sha256.Sum256([]byte(userID))
+	return "user_" + strings.TrimRight(base32.StdEncoding.EncodeToString(sum[:]), "=")
+}
+
+func sessionTTL(opts *sessions.Options) time.Duration {
+	if opts.MaxAge <= 0 {
+		return DefaultTTL
+	}
+	return time.Duration(opts.MaxAge) * time.Second
+}
+
+// newSessionID returns a random session ID, that is safe to use in
+// file names and keys.
+func newSessionID() string {
+	return strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+}
+
+// StartCleanup deletes expired sessions from backend every interval,
+// until ctx is done. Errors are logged with the logger of ctx.
+func StartCleanup(ctx context.Context, backend Backend, interval time.Duration) {
+	l := log.ForceContext(ctx).With("context", "appkit/sessions.StartCleanup")
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := backend.DeleteExpired(ctx); err != nil {
+					l.Error().Log(
+						"during", "Backend.DeleteExpired",
+						"err", err,
+						"msg", "error deleting expired sessions",
+					)
+				}
+			}
+		}
+	}()
+}
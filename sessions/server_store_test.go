@@ -0,0 +1,79 @@
+package sessions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemoryBackendDeleteExpired(t *testing.T) {
+	b := NewMemoryBackend()
+
+	b.Save(context.Background(), "expired", nil, -time.Second)
+	b.DeleteExpired(context.Background())
+	if len(b.sessions) != 0 {
+		t.Errorf("DeleteExpired left %d sessions", len(b.sessions))
+	}
+}
+
+func TestWithServerSession(t *testing.T) {
+	backend := NewMemoryBackend()
+	conf := &CookieStoreConfig{
+		Name: "test",
+		Key:  "6bude5uOm9eZV280BjP6f6a5bEj7fg2PWl6GysY68CmXfOv8NFZ9O6ZIpbllQPtr",
+	}
+
+	handler := WithServerSession(conf, backend)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/put":
+			if err := Put(r.Context(), "uid", "123"); err != nil {
+				t.Error(err)
+			}
+		case "/get":
+			v, _ := Get(r.Context(), "uid")
+			w.Write([]byte(v))
+		case "/del":
+			if err := Del(r.Context(), "uid"); err != nil {
+				t.Error(err)
+			}
+		}
+	}))
+
+	do := func(path string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	cookies := do("/put", nil).Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("got %d cookies, want 1", len(cookies))
+	}
+	if len(backend.sessions) != 1 {
+		t.Fatalf("got %d sessions in backend, want 1", len(backend.sessions))
+	}
+
+	if got := do("/get", cookies).Body.String(); got != "123" {
+		t.Errorf("Get(uid) = %q, want \"123\"", got)
+	}
+
+	do("/del", cookies)
+	if got := do("/get", cookies).Body.String(); got != "" {
+		t.Errorf("Get(uid) = %q after Del, want \"\"", got)
+	}
+
+	// Revoking the session server-side logs out the cookie
+	do("/put", cookies)
+	for id := range backend.sessions {
+		backend.Delete(context.Background(), id)
+	}
+	if got := do("/get", cookies).Body.String(); got != "" {
+		t.Errorf("Get(uid) = %q for a revoked session, want \"\"", got)
+	}
+}
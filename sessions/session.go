@@ -2,7 +2,6 @@ package sessions
 
 import (
 	"context"
-	"encoding/base64"
 	"fmt"
 	"net/http"
 
@@ -15,7 +14,19 @@ type session struct {
 	w      http.ResponseWriter
 	r      *http.Request
 	config *CookieStoreConfig
-	store  *sessions.CookieStore
+	store  sessions.Store
+
+	// dirty is set by changes to the session, that are saved once
+	// before the response headers are written.
+	dirty bool
+
+	// checked is set once the session has been checked for timeouts
+	// and revocation, see `session.check`.
+	checked bool
+
+	// checkErr is the error checking the session, returned by every
+	// later use of the session in the request.
+	checkErr error
 }
 
 type sessionContextKey int
@@ -23,9 +34,50 @@ type sessionContextKey int
 const sessionCtxKey sessionContextKey = iota
 
 // WithSession is middleware to generate a session store for the whole request lifetime.
-// later session operations should call `Get`/`Put`/`Del` to work with the session
+// later session operations should call `Get`/`Put`/`Del` to work with the session.
+// It panics if conf is invalid, use `NewSessionMiddleware` to handle the error.
 func WithSession(conf *CookieStoreConfig) func(http.Handler) http.Handler {
-	store := setupSessionStore(conf)
+	middleware, err := NewSessionMiddleware(conf)
+	if err != nil {
+		panic(err)
+	}
+	return middleware
+}
+
+// NewSessionMiddleware is like `WithSession`, but returns an error if
+// conf is invalid, see `CookieStoreConfig.Validate`.
+func NewSessionMiddleware(conf *CookieStoreConfig) (func(http.Handler) http.Handler, error) {
+	store, err := setupSessionStore(conf)
+	if err != nil {
+		return nil, err
+	}
+	return WithSessionStore(conf, store), nil
+}
+
+// WithServerSession is like `WithSession`, but keeps the session
+// values in backend, and only the session ID in the cookie.
+// It panics if conf is invalid, use `NewServerSessionMiddleware` to
+// handle the error.
+func WithServerSession(conf *CookieStoreConfig, backend Backend) func(http.Handler) http.Handler {
+	middleware, err := NewServerSessionMiddleware(conf, backend)
+	if err != nil {
+		panic(err)
+	}
+	return middleware
+}
+
+// NewServerSessionMiddleware is like `WithServerSession`, but returns
+// an error if conf is invalid, see `CookieStoreConfig.Validate`.
+func NewServerSessionMiddleware(conf *CookieStoreConfig, backend Backend) (func(http.Handler) http.Handler, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	return WithSessionStore(conf, NewServerStore(*conf, backend)), nil
+}
+
+// WithSessionStore is like `WithSession`, but uses store to keep the
+// sessions named `conf.Name`.
+func WithSessionStore(conf *CookieStoreConfig, store sessions.Store) func(http.Handler) http.Handler {
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			se := newSession(w, r, conf, store)
@@ -35,7 +87,11 @@ func WithSession(conf *CookieStoreConfig) func(http.Handler) http.Handler {
 
 			r = r.WithContext(ctx)
 
-			h.ServeHTTP(w, r)
+			sw := &saveWriter{ResponseWriter: w, session: se}
+			h.ServeHTTP(sw, r)
+
+			// Save changes if the handler didn't write a response
+			sw.save()
 		})
 	}
 }
@@ -47,7 +103,7 @@ func Get(ctx context.Context, key string) (string, error) {
 		return "", err
 	}
 
-	session, err := s.store.Get(s.r, s.config.Name)
+	session, err := s.get()
 	if err != nil {
 		return "", errors.Wrapf(err, "cannot get key %v from session", key)
 	}
@@ -65,55 +121,158 @@ func Get(ctx context.Context, key string) (string, error) {
 	return str, nil
 }
 
-// Put adds value for key into the session
+// Put adds value for key into the session. The session is saved
+// before the response is written, or by `Save`.
 func Put(ctx context.Context, key, value string) error {
+	return put(ctx, key, value)
+}
+
+func put(ctx context.Context, key string, value interface{}) error {
 	s, err := getSession(ctx)
 	if err != nil {
 		return err
 	}
 
-	session, err := s.store.Get(s.r, s.config.Name)
+	session, err := s.get()
 	if err != nil {
 		return errors.Wrapf(err, "cannot put key %v in to session", key)
 	}
 
 	session.Values[key] = value
-	session.Save(s.r, s.w)
+	s.dirty = true
 	return nil
 }
 
-// Del deletes value from the session by given key
+// Del deletes value from the session by given key. The session is
+// saved before the response is written, or by `Save`.
 func Del(ctx context.Context, key string) error {
 	s, err := getSession(ctx)
 	if err != nil {
 		return err
 	}
 
-	session, err := s.store.Get(s.r, s.config.Name)
+	session, err := s.get()
 	if err != nil {
 		return errors.Wrapf(err, "cannot delete key %v from session", key)
 	}
 
 	delete(session.Values, key)
-	session.Save(s.r, s.w)
+	s.dirty = true
 
 	return nil
 }
 
-func setupSessionStore(config *CookieStoreConfig) *sessions.CookieStore {
-	if config.Name == "" {
-		panic("session name must be present")
+// AddFlash adds a flash message to the session, that is returned
+// once by `Flashes`, eg. in the next request.
+func AddFlash(ctx context.Context, message string) error {
+	s, err := getSession(ctx)
+	if err != nil {
+		return err
 	}
 
-	if _, err := base64.StdEncoding.DecodeString(config.Key); err != nil {
-		panic(err)
+	session, err := s.get()
+	if err != nil {
+		return errors.Wrap(err, "cannot add flash to session")
 	}
 
-	return NewCookieStore(*config)
+	session.AddFlash(message)
+	s.dirty = true
+	return nil
 }
 
-func newSession(w http.ResponseWriter, r *http.Request, config *CookieStoreConfig, sessionStore *sessions.CookieStore) *session {
-	return &session{w, r, config, sessionStore}
+// Flashes returns the flash messages of the session, and removes them
+// from the session.
+func Flashes(ctx context.Context) ([]string, error) {
+	s, err := getSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.get()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get flashes from session")
+	}
+
+	flashes := session.Flashes()
+	if len(flashes) == 0 {
+		return nil, nil
+	}
+	s.dirty = true
+
+	messages := make([]string, 0, len(flashes))
+	for _, f := range flashes {
+		if m, ok := f.(string); ok {
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
+}
+
+// Save saves the changes to the session now, instead of before the
+// response is written.
+func Save(ctx context.Context) error {
+	s, err := getSession(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.save()
+}
+
+func setupSessionStore(config *CookieStoreConfig) (*sessions.CookieStore, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return NewCookieStore(*config), nil
+}
+
+func newSession(w http.ResponseWriter, r *http.Request, config *CookieStoreConfig, sessionStore sessions.Store) *session {
+	return &session{w: w, r: r, config: config, store: sessionStore}
+}
+
+func (s *session) get() (*sessions.Session, error) {
+	session, err := s.store.Get(s.r, s.config.Name)
+	if err != nil {
+		return session, err
+	}
+
+	if s.checkErr != nil {
+		return session, s.checkErr
+	}
+
+	if !s.checked {
+		if err := s.check(session); err != nil {
+			s.checkErr = err
+			return session, err
+		}
+		s.checked = true
+	}
+
+	return session, nil
+}
+
+func (s *session) save() error {
+	if !s.dirty {
+		return nil
+	}
+
+	// A failed save isn't retried on every write of the response,
+	// only after further changes
+	s.dirty = false
+
+	session, err := s.get()
+	if err != nil {
+		return errors.Wrap(err, "cannot save session")
+	}
+
+	s.touch(session)
+
+	if err := session.Save(s.r, s.w); err != nil {
+		return errors.Wrap(err, "cannot save session")
+	}
+
+	return nil
 }
 
 func getSession(ctx context.Context) (*session, error) {
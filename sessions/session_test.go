@@ -2,9 +2,14 @@ package sessions
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/theplant/appkit/log"
+	"github.com/theplant/appkit/log/logtest"
 )
 
 func TestGorillaContextMemoryleak(t *testing.T) {
@@ -46,3 +51,182 @@ func TestGorillaContextMemoryleak(t *testing.T) {
 
 	handler(testHandler).ServeHTTP(respWriter, req)
 }
+
+func testHandler(conf *CookieStoreConfig, h http.HandlerFunc) func(cookies []*http.Cookie) *httptest.ResponseRecorder {
+	handler := WithSession(conf)(h)
+	return func(cookies []*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+}
+
+var testConf = &CookieStoreConfig{
+	Name: "test",
+	Key:  "6bude5uOm9eZV280BjP6f6a5bEj7fg2PWl6GysY68CmXfOv8NFZ9O6ZIpbllQPtr",
+}
+
+func TestBatchedSave(t *testing.T) {
+	do := testHandler(testConf, func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		Put(ctx, "a", "1")
+		Put(ctx, "b", "2")
+		Del(ctx, "a")
+		if len(w.Header()["Set-Cookie"]) != 0 {
+			t.Errorf("session saved before the response is written")
+		}
+		w.Write([]byte("ok"))
+	})
+
+	resp := do(nil).Result()
+	if got := len(resp.Header["Set-Cookie"]); got != 1 {
+		t.Fatalf("got %d Set-Cookie headers, want 1", got)
+	}
+
+	do = testHandler(testConf, func(w http.ResponseWriter, r *http.Request) {
+		a, _ := Get(r.Context(), "a")
+		b, _ := Get(r.Context(), "b")
+		w.Write([]byte(a + b))
+	})
+	if got := do(resp.Cookies()).Body.String(); got != "2" {
+		t.Errorf("got %q, want \"2\"", got)
+	}
+}
+
+func TestSave(t *testing.T) {
+	do := testHandler(testConf, func(w http.ResponseWriter, r *http.Request) {
+		Put(r.Context(), "a", "1")
+		if err := Save(r.Context()); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(w.Header()["Set-Cookie"]); got != 1 {
+			t.Errorf("got %d Set-Cookie headers after Save, want 1", got)
+		}
+	})
+
+	if got := len(do(nil).Result().Header["Set-Cookie"]); got != 1 {
+		t.Errorf("got %d Set-Cookie headers, want 1", got)
+	}
+}
+
+func TestGetAs(t *testing.T) {
+	type user struct {
+		ID    int
+		Roles []string
+	}
+
+	for name, codec := range map[string]Codec{"json": JSON, "gob": Gob} {
+		t.Run(name, func(t *testing.T) {
+			want := user{ID: 123, Roles: []string{"admin"}}
+
+			put := testHandler(testConf, func(w http.ResponseWriter, r *http.Request) {
+				if err := PutAs(r.Context(), "user", want, codec); err != nil {
+					t.Fatal(err)
+				}
+			})
+
+			get := testHandler(testConf, func(w http.ResponseWriter, r *http.Request) {
+				got, err := GetAs[user](r.Context(), "user", codec)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.ID != want.ID || len(got.Roles) != 1 || got.Roles[0] != "admin" {
+					t.Errorf("GetAs = %+v, want %+v", got, want)
+				}
+
+				if _, err := GetAs[int](r.Context(), "missing", codec); !errors.Is(err, ErrNotFound) {
+					t.Errorf("GetAs(missing) error = %v, want ErrNotFound", err)
+				}
+			})
+
+			get(put(nil).Result().Cookies())
+		})
+	}
+}
+
+func TestFlashes(t *testing.T) {
+	add := testHandler(testConf, func(w http.ResponseWriter, r *http.Request) {
+		AddFlash(r.Context(), "saved")
+		AddFlash(r.Context(), "welcome")
+	})
+
+	var got []string
+	read := testHandler(testConf, func(w http.ResponseWriter, r *http.Request) {
+		got, _ = Flashes(r.Context())
+	})
+
+	resp := read(add(nil).Result().Cookies()).Result()
+	if len(got) != 2 || got[0] != "saved" || got[1] != "welcome" {
+		t.Errorf("Flashes = %v, want [saved welcome]", got)
+	}
+
+	read(resp.Cookies())
+	if len(got) != 0 {
+		t.Errorf("Flashes = %v after reading them, want none", got)
+	}
+}
+
+// failingBackend is a Backend that fails to save sessions.
+type failingBackend struct {
+	Backend
+}
+
+func (failingBackend) Save(context.Context, string, []byte, time.Duration) error {
+	return errors.New("backend unavailable")
+}
+
+func TestFailedSaveLoggedOnce(t *testing.T) {
+	logger := logtest.New(t, logtest.Quiet())
+
+	handler := WithServerSession(testConf, failingBackend{NewMemoryBackend()})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Put(r.Context(), "a", "1")
+		w.Write([]byte("a"))
+		w.Write([]byte("b"))
+		w.(http.Flusher).Flush()
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req = req.WithContext(log.Context(req.Context(), logger.Logger))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got := len(logger.FindLevel(log.LevelError)); got != 1 {
+		t.Errorf("got %d errors logged, want 1:\n%v", got, logger.Entries())
+	}
+}
+
+func TestHijack(t *testing.T) {
+	server := httptest.NewServer(WithSession(testConf)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Put(r.Context(), "a", "1")
+
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: close\r\n\r\n")
+		rw.Flush()
+	})))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("got status %d, want 101", resp.StatusCode)
+	}
+
+	// httptest.ResponseRecorder doesn't support hijacking
+	do := testHandler(testConf, func(w http.ResponseWriter, r *http.Request) {
+		if _, _, err := w.(http.Hijacker).Hijack(); err == nil {
+			t.Error("expected an error hijacking a ResponseRecorder")
+		}
+	})
+	do(nil)
+}
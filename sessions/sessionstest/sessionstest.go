@@ -0,0 +1,62 @@
+// Package sessionstest provides tests for implementations of
+// `sessions.Backend`.
+package sessionstest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/theplant/appkit/sessions"
+)
+
+// TestBackend checks that b loads, saves, expires and deletes
+// sessions as `sessions.WithServerSession` expects.
+func TestBackend(t testing.TB, b sessions.Backend) {
+	t.Helper()
+	ctx := context.Background()
+
+	if _, found, err := b.Load(ctx, "missing"); err != nil || found {
+		t.Fatalf("Load(missing) = %v, %v, want false, nil", found, err)
+	}
+
+	if err := b.Save(ctx, "live", []byte("data"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Save(ctx, "expired", []byte("data"), -time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	data, found, err := b.Load(ctx, "live")
+	if err != nil || !found || string(data) != "data" {
+		t.Fatalf("Load(live) = %q, %v, %v, want \"data\", true, nil", data, found, err)
+	}
+
+	if err := b.Save(ctx, "live", []byte("updated"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if data, _, _ := b.Load(ctx, "live"); string(data) != "updated" {
+		t.Errorf("Load(live) = %q after update, want \"updated\"", data)
+	}
+
+	if _, found, err := b.Load(ctx, "expired"); err != nil || found {
+		t.Errorf("Load(expired) = %v, %v, want false, nil", found, err)
+	}
+
+	if err := b.DeleteExpired(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := b.Load(ctx, "live"); !found {
+		t.Errorf("DeleteExpired deleted a live session")
+	}
+
+	if err := b.Delete(ctx, "live"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := b.Load(ctx, "live"); found {
+		t.Errorf("Load(live) found a deleted session")
+	}
+	if err := b.Delete(ctx, "live"); err != nil {
+		t.Errorf("Delete of a missing session: %v", err)
+	}
+}
@@ -1,6 +1,12 @@
 package sessions
 
-import "github.com/gorilla/sessions"
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/sessions"
+	"github.com/pkg/errors"
+)
 
 // CookieStoreConfig is a general cookie storage configuration. It
 // extends the `gorilla/sessions.Options` and compatible to work with
@@ -12,32 +18,111 @@ import "github.com/gorilla/sessions"
 // The `gorilla/sessions.Options`:
 // https://github.com/gorilla/sessions/blob/7910f5bb5ac86ab08f97d8bda39b476fc117b684/sessions.go#L19-L34
 type CookieStoreConfig struct {
-	Name       string `required:"true"`
-	Key        string `required:"true"`
+	Name string `required:"true"`
+
+	// Keys sign and encrypt cookies, see `CookieKey`. The first key
+	// encodes new cookies, and older keys still decode cookies, to
+	// rotate keys without ending all sessions.
+	Keys []CookieKey
+
+	// Key is a base64 key to sign cookies, that decodes cookies after
+	// Keys. Deprecated: use Keys.
+	Key string
+
 	Domain     string
 	Path       string `default:"/"`
 	MaxAge     int    `default:"2592000"` // 2592000 = 30 * 24 * 60 * 60
 	NoHTTPOnly bool
 	NoSecure   bool
+
+	// SameSite is "Lax", "Strict", "None" (that needs Secure), or
+	// empty to leave it to the browser.
+	SameSite string
+
+	// Partitioned stores the cookie per top-level site (CHIPS), for
+	// sessions of embedded third-party sites. It needs Secure, and
+	// gorilla/sessions v1.4.0 or later (Go 1.23 or later).
+	Partitioned bool
+
+	// IdleTimeout and AbsoluteTimeout end sessions that haven't been
+	// used, or have been created, more than the given seconds ago. 0
+	// disables them.
+	IdleTimeout     int
+	AbsoluteTimeout int
 }
 
 // NewCookieStore initializes a `gorilla/sessions.CookieStore` by
-// `CookieStoreConfig`.
+// `CookieStoreConfig`, that should be valid, see
+// `CookieStoreConfig.Validate`.
 //
 // The `gorilla/sessions.CookieStore`:
 // https://github.com/gorilla/sessions/blob/7910f5bb5ac86ab08f97d8bda39b476fc117b684/store.go#L66-L70
 func NewCookieStore(config CookieStoreConfig) *sessions.CookieStore {
-	cs := sessions.NewCookieStore([]byte(config.Key))
-
-	cs.Options = &sessions.Options{
-		Path:     config.Path,
-		Domain:   config.Domain,
-		MaxAge:   config.MaxAge,
-		Secure:   !config.NoSecure,
-		HttpOnly: !config.NoHTTPOnly,
-	}
+	cs := sessions.NewCookieStore(config.keyPairs()...)
+
+	cs.Options = cookieOptions(config)
 
 	cs.MaxAge(cs.Options.MaxAge)
 
 	return cs
 }
+
+func cookieOptions(config CookieStoreConfig) *sessions.Options {
+	return &sessions.Options{
+		Path:        config.Path,
+		Domain:      config.Domain,
+		MaxAge:      config.MaxAge,
+		Secure:      !config.NoSecure,
+		HttpOnly:    !config.NoHTTPOnly,
+		SameSite:    sameSite(config.SameSite),
+		Partitioned: config.Partitioned,
+	}
+}
+
+func sameSite(mode string) http.SameSite {
+	switch strings.ToLower(mode) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	}
+	return http.SameSiteDefaultMode
+}
+
+// validateCookie checks the SameSite and Partitioned attributes, and
+// the rules of the `__Secure-` and `__Host-` cookie name prefixes.
+func (c CookieStoreConfig) validateCookie() error {
+	switch strings.ToLower(c.SameSite) {
+	case "", "lax", "strict":
+	case "none":
+		if c.NoSecure {
+			return errors.New("session cookie with SameSite None must be Secure")
+		}
+	default:
+		return errors.Errorf("session SameSite must be Lax, Strict or None, got %q", c.SameSite)
+	}
+
+	if c.Partitioned && c.NoSecure {
+		return errors.New("partitioned session cookie must be Secure")
+	}
+
+	if strings.HasPrefix(c.Name, "__Secure-") && c.NoSecure {
+		return errors.Errorf("session cookie %v must be Secure", c.Name)
+	}
+
+	if strings.HasPrefix(c.Name, "__Host-") {
+		if c.NoSecure {
+			return errors.Errorf("session cookie %v must be Secure", c.Name)
+		}
+		if c.Domain != "" {
+			return errors.Errorf("session cookie %v must not have a Domain", c.Name)
+		}
+		if c.Path != "/" {
+			return errors.Errorf("session cookie %v must have Path \"/\"", c.Name)
+		}
+	}
+
+	return nil
+}
@@ -29,6 +29,8 @@ func ExampleCookieStoreConfig_Default() {
 	fmt.Printf("MaxAge: %d\n", config.MaxAge)
 	fmt.Printf("NoHTTPOnly: %t\n", config.NoHTTPOnly)
 	fmt.Printf("NoSecure: %t\n", config.NoSecure)
+	fmt.Printf("SameSite: %q\n", config.SameSite)
+	fmt.Printf("Partitioned: %t\n", config.Partitioned)
 
 	// Output:
 	// Name: "COOKIESTORE_NAME"
@@ -38,6 +40,8 @@ func ExampleCookieStoreConfig_Default() {
 	// MaxAge: 2592000
 	// NoHTTPOnly: false
 	// NoSecure: false
+	// SameSite: ""
+	// Partitioned: false
 }
 
 func ExampleCookieStoreConfig_MissingName() {
@@ -56,12 +60,15 @@ func ExampleCookieStoreConfig_MissingKey() {
 	os.Setenv("TEST_APPKIT_SESSIONS_COOKIESTORE_Name", "_cookiestore")
 	os.Unsetenv("TEST_APPKIT_SESSIONS_COOKIESTORE_Key")
 
-	_, err := loadCookieStoreConfig()
+	config, err := loadCookieStoreConfig()
+	if err != nil {
+		panic(errors.Wrap(err, "failed to load cookie store config"))
+	}
 
-	fmt.Println(err)
+	fmt.Println(config.Validate())
 
 	// Output:
-	// Key is required, but blank
+	// session Key or Keys must be present
 }
 
 func loadCookieStoreConfig() (config sessions.CookieStoreConfig, err error) {
@@ -124,6 +131,9 @@ func TestNewCookieStore(t *testing.T) {
 			if got, want := cookie.Secure, !config.NoSecure; got != want {
 				t.Errorf("got Secure = %t, but want %t", got, want)
 			}
+			if got, want := cookie.Partitioned, config.Partitioned; got != want {
+				t.Errorf("got Partitioned = %t, but want %t", got, want)
+			}
 		}
 	}
 
@@ -131,4 +141,22 @@ func TestNewCookieStore(t *testing.T) {
 
 	t.Run("Default", runCase(C{Name: "cookiestore_name", Key: "secret"}))
 	t.Run("Customized", runCase(C{Name: "name", Key: "secret", Path: "/cookie-path", Domain: "hello.local", MaxAge: 60, NoHTTPOnly: true, NoSecure: true}))
+	t.Run("Partitioned", runCase(C{Name: "name", Key: "secret", Path: "/", SameSite: "None", Partitioned: true}))
+
+	t.Run("SameSite", func(t *testing.T) {
+		for mode, want := range map[string]http.SameSite{
+			"":       0, // no SameSite attribute
+			"Lax":    http.SameSiteLaxMode,
+			"strict": http.SameSiteStrictMode,
+			"None":   http.SameSiteNoneMode,
+		} {
+			cookie, err := write(C{Name: "name", Key: "secret", Path: "/", SameSite: mode})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cookie.SameSite != want {
+				t.Errorf("SameSite %q: got %v, but want %v", mode, cookie.SameSite, want)
+			}
+		}
+	})
 }
@@ -0,0 +1,78 @@
+package sessions
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/theplant/appkit/log"
+)
+
+// saveWriter saves the changes to the session before the response
+// headers are written, so that the session cookie is set once per
+// request.
+type saveWriter struct {
+	http.ResponseWriter
+	session *session
+}
+
+func (w *saveWriter) save() {
+	if err := w.session.save(); err != nil {
+		log.ForceContext(w.session.r.Context()).Error().Log(
+			"during", "sessions.saveWriter.save",
+			"err", err,
+			"msg", "error saving session",
+		)
+	}
+}
+
+func (w *saveWriter) WriteHeader(code int) {
+	w.save()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *saveWriter) Write(data []byte) (int, error) {
+	w.save()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *saveWriter) Flush() {
+	w.save()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack saves the session and hijacks the connection, for
+// websockets and the like.
+func (w *saveWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.save()
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("sessions: the ResponseWriter does not support hijacking")
+	}
+	return h.Hijack()
+}
+
+// ReadFrom saves the session and keeps the original ResponseWriter's
+// `io.ReaderFrom`, eg. sendfile for `http.ServeFile`.
+func (w *saveWriter) ReadFrom(r io.Reader) (int64, error) {
+	w.save()
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+	return io.Copy(writerOnly{w.ResponseWriter}, r)
+}
+
+// writerOnly hides the ReadFrom of a writer from io.Copy.
+type writerOnly struct {
+	io.Writer
+}
+
+// Unwrap returns the original ResponseWriter, for
+// `http.ResponseController`.
+func (w *saveWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}